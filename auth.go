@@ -0,0 +1,262 @@
+package egs
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/Yuukirn/egs/security"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// handlers returns the handlers of r registered to gin. The authentication of its
+// securities runs first, and its authorization after the middlewares of r and its
+// groups but before the request is bound, so the middlewares can authenticate the
+// caller themselves for securities which only document it, setting the principal
+// at security.PrincipalKey, and unauthorized requests are rejected before binding.
+func handlers(r *router.Router) []gin.HandlerFunc {
+	var handlers []gin.HandlerFunc
+	if auth := authenticate(r); auth != nil {
+		handlers = append(handlers, auth)
+	}
+	handlers = append(handlers, r.Handlers...)
+	if auth := authorize(r); auth != nil {
+		handlers = append(handlers, auth)
+	}
+	if r.Bind != nil {
+		handlers = append(handlers, r.Bind)
+	}
+	return append(handlers, r.API)
+}
+
+// authenticators returns the enforced authenticators of each alternative set of
+// securities required by r, or nil if none of its securities are enforced. The
+// securities which aren't enforced are left to the middlewares, so an alternative
+// of such securities only is empty.
+func authenticators(r *router.Router) [][]security.Authenticator {
+	if r.Public {
+		return nil
+	}
+	var alternatives [][]security.Authenticator
+	enforced := false
+	for _, requirement := range security.Requirements(r.Securities) {
+		var authenticators []security.Authenticator
+		for _, s := range requirement {
+			if authenticator, ok := s.(security.Authenticator); ok && authenticator.Enforced() {
+				authenticators = append(authenticators, authenticator)
+			}
+		}
+		enforced = enforced || len(authenticators) != 0
+		alternatives = append(alternatives, authenticators)
+	}
+	if !enforced {
+		return nil
+	}
+	return alternatives
+}
+
+// deferred reports whether an alternative of alternatives is left to the middlewares,
+// which then have to set the principal
+func deferred(alternatives [][]security.Authenticator) bool {
+	for _, authenticators := range alternatives {
+		if len(authenticators) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the middleware accepting the requests authenticated by all
+// the enforced authenticators of any alternative, or nil if the securities aren't
+// enforced. If an alternative is left to the middlewares, the other requests are
+// passed on to them, and rejected by authorize if they don't set a principal.
+func authenticate(r *router.Router) gin.HandlerFunc {
+	alternatives := authenticators(r)
+	if len(alternatives) == 0 {
+		return nil
+	}
+	deferred := deferred(alternatives)
+
+	return func(c *gin.Context) {
+		for _, authenticators := range alternatives {
+			if len(authenticators) == 0 {
+				continue
+			}
+			principal, err := authenticateAll(c, authenticators)
+			if err == nil {
+				c.Set(security.PrincipalKey, principal)
+				c.Next()
+				return
+			}
+			if !errors.Is(err, security.ErrNoCredentials) {
+				_ = c.Error(err)
+			}
+		}
+		if deferred {
+			c.Next()
+			return
+		}
+		unauthorized(c, alternatives)
+	}
+}
+
+// unauthorized rejects the request with 401 and the challenges of alternatives
+func unauthorized(c *gin.Context, alternatives [][]security.Authenticator) {
+	challenges := make(map[string]bool)
+	for _, authenticators := range alternatives {
+		for _, authenticator := range authenticators {
+			if challenger, ok := authenticator.(security.Challenger); ok && !challenges[challenger.Challenge()] {
+				challenges[challenger.Challenge()] = true
+				c.Writer.Header().Add("WWW-Authenticate", challenger.Challenge())
+			}
+		}
+	}
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
+// authenticateAll returns the principal of the first authenticator, with the scopes,
+// roles and permissions of all of them
+func authenticateAll(c *gin.Context, authenticators []security.Authenticator) (*security.Principal, error) {
+	if len(authenticators) == 0 {
+		return nil, security.ErrNoCredentials
+	}
+	var principal *security.Principal
+	for _, authenticator := range authenticators {
+		p, err := authenticator.Authenticate(c)
+		if err != nil {
+			return nil, err
+		}
+		if principal == nil {
+			principal = p
+			continue
+		}
+		// merge into a copy, the principals of stores may be cached and shared
+		merged := *principal
+		merged.Scopes = append(append([]string(nil), principal.Scopes...), p.Scopes...)
+		merged.Roles = append(append([]string(nil), principal.Roles...), p.Roles...)
+		merged.Permissions = append(append([]string(nil), principal.Permissions...), p.Permissions...)
+		principal = &merged
+	}
+	return principal, nil
+}
+
+// requiresAuthorization reports whether r requires scopes, roles, permissions or policies
+func requiresAuthorization(r *router.Router) bool {
+	return !r.Public && (len(r.Scopes) != 0 || len(r.Roles) != 0 || len(r.Permissions) != 0 || len(r.Policies) != 0)
+}
+
+// authorize returns the middleware rejecting the principals without the scopes,
+// roles and permissions of r or rejected by its policies with 403, and the
+// requests without a principal, set by the securities or the middlewares, with 401.
+// It also runs for the routers with an alternative left to the middlewares.
+func authorize(r *router.Router) gin.HandlerFunc {
+	alternatives := authenticators(r)
+	if !requiresAuthorization(r) && !deferred(alternatives) {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		principal, ok := security.GetPrincipal(c)
+		if !ok {
+			unauthorized(c, alternatives)
+			return
+		}
+		if !containsAll(principal.Scopes, r.Scopes) || !containsAll(principal.Permissions, r.Permissions) ||
+			(len(r.Roles) != 0 && !containsAny(principal.Roles, r.Roles)) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		for _, policy := range r.Policies {
+			if err := policy(c, principal); err != nil {
+				_ = c.Error(err)
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func containsAll(s, values []string) bool {
+	for _, v := range values {
+		if !contains(s, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(s, values []string) bool {
+	for _, v := range values {
+		if contains(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// unauthorizedResponse documents the 401 responses of the routers enforcing their securities
+func unauthorizedResponse() *openapi3.ResponseRef {
+	description := "The request is not authenticated"
+	return &openapi3.ResponseRef{
+		Value: &openapi3.Response{
+			Description: &description,
+		},
+	}
+}
+
+// forbiddenResponse documents the 403 responses of the routers requiring authorization
+func forbiddenResponse() *openapi3.ResponseRef {
+	description := "The caller is not allowed to perform the operation"
+	return &openapi3.ResponseRef{
+		Value: &openapi3.Response{
+			Description: &description,
+		},
+	}
+}
+
+// permissionsExtension documents the authorization of r as `x-permissions`
+func permissionsExtension(r *router.Router) map[string]any {
+	permissions := make(map[string]any)
+	if len(r.Scopes) != 0 {
+		permissions["scopes"] = r.Scopes
+	}
+	if len(r.Roles) != 0 {
+		permissions["roles"] = r.Roles
+	}
+	if len(r.Permissions) != 0 {
+		permissions["permissions"] = r.Permissions
+	}
+	return permissions
+}
+
+// securityParameters returns the parameters required by the securities of r, which
+// are optional if r accepts alternatives without them
+func securityParameters(r *router.Router) openapi3.Parameters {
+	if r.Public {
+		return nil
+	}
+	requirements := security.Requirements(r.Securities)
+	var parameters openapi3.Parameters
+	for _, requirement := range requirements {
+		for _, s := range requirement {
+			withParameters, ok := s.(security.Parameters)
+			if !ok {
+				continue
+			}
+			for _, parameter := range withParameters.Parameters() {
+				if parameters.GetByInAndName(parameter.Value.In, parameter.Value.Name) != nil {
+					continue
+				}
+				if len(requirements) > 1 {
+					optional := *parameter.Value
+					optional.Required = false
+					parameter = &openapi3.ParameterRef{Value: &optional}
+				}
+				parameters = append(parameters, parameter)
+			}
+		}
+	}
+	return parameters
+}
@@ -0,0 +1,400 @@
+package egs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/Yuukirn/egs/security"
+	"github.com/gin-gonic/gin"
+)
+
+// cachedStore returns the same principal for every request, like a store caching its principals
+type cachedStore struct {
+	principal *security.Principal
+}
+
+func (s cachedStore) Verify(ctx context.Context, _ string) (*security.Principal, error) {
+	return s.principal, nil
+}
+
+type cachedCredentials struct {
+	principal *security.Principal
+}
+
+func (s cachedCredentials) Verify(ctx context.Context, _, _ string) (*security.Principal, error) {
+	return s.principal, nil
+}
+
+func TestAuthenticateKeepsStorePrincipals(t *testing.T) {
+	user := &security.Principal{Scopes: make([]string, 1, 8), Roles: []string{"admin"}}
+	user.Scopes[0] = "read"
+	key := &security.Principal{Subject: "key", Scopes: []string{"write"}}
+
+	basic := &security.Basic{AuthName: "basic", Store: cachedCredentials{user}}
+	apiKey := security.MustHeaderApiKey("key", "X-API-Key", cachedStore{key})
+
+	app := New(NewSwagger("auth", "", "1.0.0"), Middlewares())
+	app.GET("/items", router.NewRouterX(func(c *gin.Context) {
+		principal, _ := security.GetPrincipal(c)
+		if !reflect.DeepEqual(principal.Scopes, []string{"read", "write"}) || principal.Security != "basic" {
+			t.Errorf("principal = %+v", principal)
+		}
+	}, router.Security(security.All(basic, apiKey))))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.SetBasicAuth("alice", "secret")
+			req.Header.Set("X-API-Key", "k")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("got %d", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(user.Scopes, []string{"read"}) || user.Security != "" || user.Subject != "" {
+		t.Errorf("the principal of the credential store changed: %+v", user)
+	}
+	if !reflect.DeepEqual(key.Scopes, []string{"write"}) || key.Security != "" {
+		t.Errorf("the principal of the key store changed: %+v", key)
+	}
+}
+
+func TestApiKeyLocations(t *testing.T) {
+	store := security.Keys{"secret": "client"}
+	tests := []struct {
+		in     string
+		apiKey *security.ApiKey
+		send   func(req *http.Request)
+	}{
+		{"header", security.MustHeaderApiKey("key", "X-API-Key", store), func(req *http.Request) {
+			req.Header.Set("X-API-Key", "secret")
+		}},
+		{"query", security.MustQueryApiKey("key", "api_key", store), func(req *http.Request) {
+			req.URL.RawQuery = "api_key=secret"
+		}},
+		{"cookie", security.MustCookieApiKey("key", "sid", store), func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: "sid", Value: "secret"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			app := New(NewSwagger("api key", "", "1.0.0"), Middlewares())
+			app.GET("/items", router.NewRouterX(func(c *gin.Context) {
+				principal, _ := security.GetPrincipal(c)
+				c.String(http.StatusOK, principal.Subject)
+			}, router.Security(tt.apiKey)))
+			handler, err := app.Handler()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scheme := app.Swagger.OpenAPI.Components.SecuritySchemes["key"].Value
+			if scheme.Type != "apiKey" || scheme.In != tt.in || scheme.Name != tt.apiKey.ParamName() {
+				t.Errorf("scheme = %s in %s named %s", scheme.Type, scheme.In, scheme.Name)
+			}
+			if app.Swagger.OpenAPI.Paths["/items"].Get.Responses["401"] == nil {
+				t.Error("401 response is not documented")
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			tt.send(req)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || w.Body.String() != "client" {
+				t.Errorf("with the key: got %d %q", w.Code, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("without the key: got %d, want 401", w.Code)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/items", nil)
+			tt.send(req)
+			req.Header.Set("X-API-Key", "wrong")
+			req.URL.RawQuery = "api_key=wrong"
+			req.Header.Set("Cookie", "sid=wrong")
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("with a wrong key: got %d, want 401", w.Code)
+			}
+		})
+	}
+}
+
+func TestNewApiKeyRejectsInvalidLocations(t *testing.T) {
+	if _, err := security.NewApiKey("key", "X-API-Key", "body", nil); err == nil {
+		t.Error("NewApiKey accepted the body location")
+	}
+	if _, err := security.NewApiKey("key", "", "header", nil); err == nil {
+		t.Error("NewApiKey accepted an empty name")
+	}
+}
+
+func TestEmptyCombinationsAreRejected(t *testing.T) {
+	basic := &security.Basic{AuthName: "basic", Store: security.Credentials{"alice": "secret"}}
+	tests := []struct {
+		name     string
+		security security.Security
+	}{
+		{"All", security.All()},
+		{"Any", security.Any()},
+		{"nested", security.Any(basic, security.All())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(NewSwagger("empty", "", "1.0.0"))
+			app.GET("/items", router.NewRouterX(func(c *gin.Context) {},
+				router.Security(tt.security), router.Roles("admin")))
+			if _, err := app.Build(); err == nil {
+				t.Error("Build accepted an empty combination")
+			}
+		})
+	}
+}
+
+func TestAuthenticateAllWithoutAuthenticators(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if principal, err := authenticateAll(c, nil); principal != nil || err != security.ErrNoCredentials {
+		t.Errorf("authenticateAll(nil) = %v, %v, want ErrNoCredentials", principal, err)
+	}
+}
+
+func TestAuthorizeAfterMiddlewares(t *testing.T) {
+	oauth2 := &security.OAuth2{AuthName: "oauth2", ClientCredentials: &security.OAuthFlow{
+		TokenURL: "https://auth.example/token",
+		Scopes:   map[string]string{"a": "scope a"},
+	}}
+	// principal authenticates the caller like a hand-written auth middleware
+	principal := func(c *gin.Context) {
+		if scopes := c.GetHeader("X-Scopes"); scopes != "" {
+			c.Set(security.PrincipalKey, &security.Principal{Subject: "alice", Scopes: []string{scopes}})
+		}
+	}
+
+	app := New(NewSwagger("authorize", "", "1.0.0"), Middlewares())
+	group := app.Group("/api", Handlers(principal), Security(oauth2))
+	group.GET("/items", router.NewRouterX(func(c *gin.Context) {}, router.Scopes("a")))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		scopes string
+		code   int
+	}{
+		{"with scope", "a", http.StatusOK},
+		{"without scope", "b", http.StatusForbidden},
+		{"no principal", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+			if tt.scopes != "" {
+				req.Header.Set("X-Scopes", tt.scopes)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeEnforcedSecurity(t *testing.T) {
+	basic := &security.Basic{AuthName: "basic", Store: security.Credentials{"alice": "secret"}}
+	app := New(NewSwagger("authorize", "", "1.0.0"), Middlewares())
+	app.GET("/items", router.NewRouterX(func(c *gin.Context) {},
+		router.Security(basic),
+		router.Policies(func(c *gin.Context, principal *security.Principal) error {
+			if principal.Subject != "alice" {
+				return errors.New("not alice")
+			}
+			return nil
+		})))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.SetBasicAuth("alice", "secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("authenticated: got %d, want 200", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: got %d, want 401", w.Code)
+	}
+}
+
+func TestGroupSecurityWithDocumentedRouterSecurity(t *testing.T) {
+	basic := &security.Basic{AuthName: "basic", Store: security.Credentials{"alice": "secret"}}
+	// jwt only documents the authentication, the middleware authenticates its callers
+	jwt := &security.Bearer{AuthName: "jwt"}
+	middleware := func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "Bearer token" {
+			c.Set(security.PrincipalKey, &security.Principal{Subject: "bob"})
+		}
+	}
+
+	app := New(NewSwagger("mixed", "", "1.0.0"), Middlewares())
+	group := app.Group("/api", Handlers(middleware), Security(basic))
+	group.GET("/a", router.NewRouterX(func(c *gin.Context) {}))
+	group.GET("/b", router.NewRouterX(func(c *gin.Context) {}, router.Security(jwt)))
+	app.GET("/docs-only", router.NewRouterX(func(c *gin.Context) {}, router.Security(jwt)))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		auth func(req *http.Request)
+		code int
+	}{
+		{"a anonymous", "/api/a", nil, http.StatusUnauthorized},
+		{"a basic", "/api/a", func(req *http.Request) { req.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"b anonymous", "/api/b", nil, http.StatusUnauthorized},
+		{"b wrong basic", "/api/b", func(req *http.Request) { req.SetBasicAuth("alice", "wrong") }, http.StatusUnauthorized},
+		{"b basic", "/api/b", func(req *http.Request) { req.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"b middleware", "/api/b", func(req *http.Request) { req.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		// securities which only document the authentication aren't enforced
+		{"docs only anonymous", "/docs-only", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != nil {
+				tt.auth(req)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Basic" {
+				t.Errorf("WWW-Authenticate = %q, want Basic", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAllWithDocumentedSecurity(t *testing.T) {
+	basic := &security.Basic{AuthName: "basic", Store: security.Credentials{"alice": "secret"}}
+	apiKey := security.MustHeaderApiKey("key", "X-API-Key", security.Keys{"k": "client"})
+	jwt := &security.Bearer{AuthName: "jwt"}
+
+	app := New(NewSwagger("all", "", "1.0.0"), Middlewares())
+	// the enforced basic is checked, jwt is left to the middlewares
+	app.GET("/mixed", router.NewRouterX(func(c *gin.Context) {}, router.Security(security.All(basic, jwt))))
+	app.GET("/both", router.NewRouterX(func(c *gin.Context) {}, router.Security(security.All(basic, apiKey))))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withBasic := func(req *http.Request) { req.SetBasicAuth("alice", "secret") }
+	withKey := func(req *http.Request) { req.Header.Set("X-API-Key", "k") }
+	tests := []struct {
+		name string
+		path string
+		auth []func(req *http.Request)
+		code int
+	}{
+		{"mixed anonymous", "/mixed", nil, http.StatusUnauthorized},
+		{"mixed basic", "/mixed", []func(*http.Request){withBasic}, http.StatusOK},
+		{"both anonymous", "/both", nil, http.StatusUnauthorized},
+		{"both basic only", "/both", []func(*http.Request){withBasic}, http.StatusUnauthorized},
+		{"both key only", "/both", []func(*http.Request){withKey}, http.StatusUnauthorized},
+		{"both", "/both", []func(*http.Request){withBasic, withKey}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for _, auth := range tt.auth {
+				auth(req)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
+
+type authorizeBindReq struct {
+	Age int `json:"age" validate:"gte=0"`
+}
+
+func TestAuthorizeBeforeBinding(t *testing.T) {
+	oauth2 := &security.OAuth2{AuthName: "oauth2", ClientCredentials: &security.OAuthFlow{
+		TokenURL: "https://auth.example/token",
+		Scopes:   map[string]string{"a": "scope a"},
+	}}
+	principal := func(c *gin.Context) {
+		c.Set(security.PrincipalKey, &security.Principal{Subject: "alice", Scopes: []string{c.GetHeader("X-Scopes")}})
+	}
+
+	app := New(NewSwagger("authorize", "", "1.0.0"), Middlewares())
+	group := app.Group("/api", Handlers(principal), Security(oauth2))
+	group.POST("/items", router.NewRouter(func(c *gin.Context, req authorizeBindReq) {}, router.Scopes("a")))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		scopes string
+		body   string
+		code   int
+	}{
+		// the request of a caller without the scope isn't bound
+		{"invalid body without scope", "b", `{"age":-1}`, http.StatusForbidden},
+		{"invalid body with scope", "a", `{"age":-1}`, http.StatusBadRequest},
+		{"valid body with scope", "a", `{"age":1}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Scopes", tt.scopes)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Command egsdoc extracts the doc comments of types, struct fields and
+// functions from go packages and generates a file registering them with
+// egs.RegisterDocs, so they are used as descriptions and summaries in the
+// generated OpenAPI document.
+//
+// Usage:
+//
+//	//go:generate go run github.com/Yuukirn/egs/cmd/egsdoc -o egs_docs.go . ./handlers
+//
+// Packages are given as `go list` patterns and default to the current directory.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type listedPackage struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	GoFiles    []string
+}
+
+type docs struct {
+	types  map[string]string
+	fields map[string]string
+	funcs  map[string]string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("egsdoc: ")
+
+	output := flag.String("o", "egs_docs.go", "output file")
+	pkgName := flag.String("package", "", "package name of the output file, defaults to the package in the output directory")
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d := docs{
+		types:  make(map[string]string),
+		fields: make(map[string]string),
+		funcs:  make(map[string]string),
+	}
+	for _, pkg := range pkgs {
+		if err := d.collect(pkg, *output); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	name := *pkgName
+	if name == "" {
+		name, err = outputPackageName(filepath.Dir(*output))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	src, err := d.generate(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func listPackages(patterns []string) ([]listedPackage, error) {
+	args := append([]string{"list", "-json"}, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+
+	var pkgs []listedPackage
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func outputPackageName(dir string) (string, error) {
+	pkgs, err := listPackages([]string{dir})
+	if err != nil {
+		return "", err
+	}
+	if len(pkgs) == 0 || pkgs[0].Name == "" {
+		return "", fmt.Errorf("can't find the package of %s, use -package", dir)
+	}
+	return pkgs[0].Name, nil
+}
+
+func (d docs) collect(pkg listedPackage, output string) error {
+	fset := token.NewFileSet()
+	outputPath, _ := filepath.Abs(output)
+
+	var files []*ast.File
+	for _, name := range pkg.GoFiles {
+		path := filepath.Join(pkg.Dir, name)
+		if path == outputPath {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	p, err := doc.NewFromFiles(fset, files, pkg.ImportPath, doc.AllDecls|doc.PreserveAST)
+	if err != nil {
+		return err
+	}
+
+	// the runtime names functions and types of main packages as `main.X`
+	prefix := pkg.ImportPath
+	if pkg.Name == "main" {
+		prefix = "main"
+	}
+
+	for _, f := range p.Funcs {
+		d.addFunc(prefix, f)
+	}
+	for _, t := range p.Types {
+		if text := strings.TrimSpace(t.Doc); text != "" {
+			d.types[prefix+"."+t.Name] = text
+		}
+		d.collectFields(prefix+"."+t.Name, t.Decl)
+		for _, f := range t.Funcs {
+			d.addFunc(prefix, f)
+		}
+		for _, f := range t.Methods {
+			d.addFunc(prefix, f)
+		}
+	}
+	return nil
+}
+
+func (d docs) addFunc(prefix string, f *doc.Func) {
+	text := strings.TrimSpace(f.Doc)
+	if text == "" {
+		return
+	}
+	name := f.Name
+	if f.Recv != "" {
+		recv := strings.TrimPrefix(f.Recv, "*")
+		// drop type parameters of generic receivers
+		if i := strings.Index(recv, "["); i >= 0 {
+			recv = recv[:i]
+		}
+		if strings.HasPrefix(f.Recv, "*") {
+			name = "(*" + recv + ")." + name
+		} else {
+			name = recv + "." + name
+		}
+	}
+	d.funcs[prefix+"."+name] = text
+}
+
+func (d docs) collectFields(typeName string, decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		typeSpec, ok := spec.(*ast.TypeSpec)
+		if !ok || prefixName(typeName) != typeSpec.Name.Name {
+			continue
+		}
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		for _, field := range structType.Fields.List {
+			text := strings.TrimSpace(field.Doc.Text())
+			if text == "" {
+				text = strings.TrimSpace(field.Comment.Text())
+			}
+			if text == "" {
+				continue
+			}
+			for _, name := range field.Names {
+				d.fields[typeName+"."+name.Name] = text
+			}
+		}
+	}
+}
+
+func prefixName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func (d docs) generate(pkgName string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by egsdoc. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	buf.WriteString("import \"github.com/Yuukirn/egs\"\n\n")
+	buf.WriteString("func init() {\n")
+	buf.WriteString("egs.RegisterDocs(egs.Docs{\n")
+	writeMap(&buf, "Types", d.types)
+	writeMap(&buf, "Fields", d.fields)
+	writeMap(&buf, "Funcs", d.funcs)
+	buf.WriteString("})\n")
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+func writeMap(buf *bytes.Buffer, name string, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(buf, "%s: map[string]string{\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s: %s,\n", strconv.Quote(k), strconv.Quote(m[k]))
+	}
+	buf.WriteString("},\n")
+}
@@ -0,0 +1,84 @@
+package egs
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Docs are descriptions taken from go doc comments, usually generated by
+// cmd/egsdoc and registered in an init function of the generated file.
+type Docs struct {
+	// Types maps `import/path.Type` to the doc of the type
+	Types map[string]string
+	// Fields maps `import/path.Type.Field` to the doc of the struct field
+	Fields map[string]string
+	// Funcs maps `import/path.Func` and `import/path.(*Type).Method` to the doc of the handler
+	Funcs map[string]string
+}
+
+var registry = struct {
+	sync.RWMutex
+	docs Docs
+}{
+	docs: Docs{
+		Types:  make(map[string]string),
+		Fields: make(map[string]string),
+		Funcs:  make(map[string]string),
+	},
+}
+
+// RegisterDocs merges docs into the registry used by Swagger.BuildOpenAPI.
+// Descriptions and summaries set on routers and struct tags take precedence.
+func RegisterDocs(docs Docs) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for k, v := range docs.Types {
+		registry.docs.Types[k] = v
+	}
+	for k, v := range docs.Fields {
+		registry.docs.Fields[k] = v
+	}
+	for k, v := range docs.Funcs {
+		registry.docs.Funcs[k] = v
+	}
+}
+
+func typeDoc(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.docs.Types[t.PkgPath()+"."+t.Name()]
+}
+
+func fieldDoc(t reflect.Type, field reflect.StructField) string {
+	if t.Name() == "" {
+		return ""
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.docs.Fields[t.PkgPath()+"."+t.Name()+"."+field.Name]
+}
+
+func funcDoc(name string) string {
+	if name == "" {
+		return ""
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.docs.Funcs[name]
+}
+
+// splitDoc returns the first sentence of doc as summary and the whole doc as description
+func splitDoc(doc string) (summary, description string) {
+	description = strings.TrimSpace(doc)
+	paragraph, _, _ := strings.Cut(description, "\n\n")
+	summary = strings.Join(strings.Fields(paragraph), " ")
+	if i := strings.Index(summary, ". "); i >= 0 {
+		summary = summary[:i+1]
+	}
+	return summary, description
+}
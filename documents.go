@@ -0,0 +1,118 @@
+package egs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Yuukirn/egs/router"
+)
+
+// RouteFilter selects the routers documented by a document, group is nil for
+// routers registered to the engine directly
+type RouteFilter func(r *router.Router, group *Group) bool
+
+// ByGroup selects the routers registered to groups or to the groups created from them
+func ByGroup(groups ...*Group) RouteFilter {
+	return func(r *router.Router, group *Group) bool {
+		for ; group != nil; group = group.parent {
+			for _, g := range groups {
+				if g == group {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ByPrefix selects the routers whose path is or starts with one of prefixes,
+// matching whole segments, so `/v1` selects `/v1/users` but not `/v10/users`
+func ByPrefix(prefixes ...string) RouteFilter {
+	return func(r *router.Router, group *Group) bool {
+		for _, prefix := range prefixes {
+			prefix = strings.TrimSuffix(joinPaths("/", prefix), "/")
+			if r.Path == prefix || strings.HasPrefix(r.Path, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByTag selects the routers with one of tags
+func ByTag(tags ...string) RouteFilter {
+	return func(r *router.Router, group *Group) bool {
+		for _, tag := range r.Tags {
+			if contains(tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// selects reports whether r is documented, routers selected by any of the filters
+// are documented, all of them when there are no filters
+func (swagger *Swagger) selects(r *router.Router) bool {
+	if len(swagger.Filters) == 0 {
+		return true
+	}
+	group := swagger.Routers.Group(r)
+	for _, filter := range swagger.Filters {
+		if filter(r, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// name is the name of the document in the Swagger UI
+func (swagger *Swagger) name() string {
+	if swagger.Name != "" {
+		return swagger.Name
+	}
+	return strings.TrimSpace(swagger.Title + " " + swagger.Version)
+}
+
+// swaggerUIOptions adds the `urls` of the other documents to SwaggerOptions
+func (swagger *Swagger) swaggerUIOptions(documents []*Swagger) map[string]any {
+	options := make(map[string]any, len(swagger.SwaggerOptions)+2)
+	for k, v := range swagger.SwaggerOptions {
+		options[k] = v
+	}
+	if len(documents) < 2 {
+		return options
+	}
+
+	urls := make([]map[string]string, 0, len(documents))
+	for _, document := range documents {
+		urls = append(urls, map[string]string{
+			"url":  document.OpenAPIUrl,
+			"name": document.name(),
+		})
+	}
+	options["urls"] = urls
+	options["urls.primaryName"] = swagger.name()
+	return options
+}
+
+// checkDocumentUrls returns an error if documents are served at the same url
+func checkDocumentUrls(documents []*Swagger) error {
+	served := make(map[string]*Swagger)
+	for _, swagger := range documents {
+		urls := []string{swagger.OpenAPIUrl, swagger.Swagger2Url, swagger.DocsUrl, swagger.RedocUrl}
+		if swagger.DocsUrl != "" {
+			urls = append(urls, swagger.oauth2RedirectUrl())
+		}
+		for _, url := range urls {
+			if url == "" {
+				continue
+			}
+			if other, ok := served[url]; ok {
+				return fmt.Errorf("document %s: url %s is already served by document %s", swagger.name(), url, other.name())
+			}
+			served[url] = swagger
+		}
+	}
+	return nil
+}
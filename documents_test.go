@@ -0,0 +1,170 @@
+package egs
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/gin-gonic/gin"
+)
+
+// newDocument returns a document served under prefix
+func newDocument(name, prefix string) *Swagger {
+	swagger := NewSwagger(name, "", "1.0.0")
+	swagger.Name = name
+	swagger.OpenAPIUrl = prefix + "/openapi.json"
+	swagger.DocsUrl = prefix + "/docs"
+	swagger.RedocUrl = prefix + "/redoc"
+	return swagger
+}
+
+func TestDocumentFilters(t *testing.T) {
+	api := func(c *gin.Context) {}
+	app := New(NewSwagger("all", "", "1.0.0"), Middlewares())
+	v1 := app.Group("/v1")
+	v1.GET("/users", router.NewRouterX(api))
+	v1.Group("/admin").GET("/users", router.NewRouterX(api))
+	app.GET("/v2/users", router.NewRouterX(api, router.Tags("users")))
+	app.GET("/v10/users", router.NewRouterX(api))
+	app.GET("/health", router.NewRouterX(api, router.Tags("ops")))
+
+	byGroup := app.Document(newDocument("v1", "/v1"), ByGroup(v1))
+	byPrefix := app.Document(newDocument("v2", "/v2"), ByPrefix("/v2"))
+	byTag := app.Document(newDocument("ops", "/ops"), ByTag("ops"))
+	app.MustBuild()
+
+	tests := []struct {
+		document *Swagger
+		paths    []string
+	}{
+		{app.Swagger, []string{"/health", "/v1/admin/users", "/v1/users", "/v10/users", "/v2/users"}},
+		{byGroup, []string{"/v1/admin/users", "/v1/users"}},
+		{byPrefix, []string{"/v2/users"}},
+		{byTag, []string{"/health"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.document.name(), func(t *testing.T) {
+			var paths []string
+			for path := range tt.document.OpenAPI.Paths {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("paths = %v, want %v", paths, tt.paths)
+			}
+
+			// every document is served at its own url
+			w := serve(t, app, http.MethodGet, tt.document.OpenAPIUrl)
+			var served struct {
+				Info struct{ Title string }
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil || served.Info.Title != tt.document.Title {
+				t.Errorf("%s served %q, %v", tt.document.OpenAPIUrl, served.Info.Title, err)
+			}
+		})
+	}
+
+	// the Swagger UI of each document lists all of them
+	options := byPrefix.swaggerUIOptions(app.documents())
+	urls := options["urls"].([]map[string]string)
+	var listed []string
+	for _, url := range urls {
+		listed = append(listed, url["name"]+" "+url["url"])
+	}
+	want := []string{"all 1.0.0 /openapi.json", "v1 /v1/openapi.json", "v2 /v2/openapi.json", "ops /ops/openapi.json"}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("urls = %v, want %v", listed, want)
+	}
+	if options["urls.primaryName"] != "v2" {
+		t.Errorf("urls.primaryName = %v, want v2", options["urls.primaryName"])
+	}
+	if _, ok := app.Swagger.swaggerUIOptions(nil)["urls"]; ok {
+		t.Error("urls are listed for a single document")
+	}
+}
+
+func TestDocumentUrlsMustDiffer(t *testing.T) {
+	app := New(NewSwagger("all", "", "1.0.0"))
+	app.GET("/users", router.NewRouterX(func(c *gin.Context) {}))
+	other := newDocument("other", "/other")
+	other.RedocUrl = "/redoc"
+	app.Document(other)
+
+	_, err := app.Build()
+	if err == nil || !strings.Contains(err.Error(), "url /redoc is already served by document all 1.0.0") {
+		t.Errorf("err = %v, want the url served twice", err)
+	}
+}
+
+type audienceCredentials struct {
+	Token string `json:"token"`
+}
+
+type audienceUser struct {
+	Name        string              `json:"name"`
+	Note        string              `json:"note" egs:"internal"`
+	Credentials audienceCredentials `json:"credentials" egs:"internal"`
+}
+
+type audienceStats struct {
+	Users int `json:"users"`
+}
+
+func TestDocumentAudiences(t *testing.T) {
+	response := func(model any) router.Option {
+		return router.Resp(router.Response{"200": router.ResponseItem{Model: model}})
+	}
+	public := NewSwagger("public", "", "1.0.0")
+	public.Audiences = []string{"public"}
+
+	app := New(public)
+	app.GET("/users", router.NewRouterX(func(c *gin.Context) {}, response(&audienceUser{})))
+	app.GET("/stats", router.NewRouterX(func(c *gin.Context) {}, response(&audienceStats{}), router.Audience("internal")))
+	internal := app.Document(newDocument("internal", "/internal"))
+	internal.Audiences = []string{"internal"}
+	app.MustBuild()
+
+	tests := []struct {
+		document   *Swagger
+		paths      []string
+		schemas    []string
+		properties []string
+	}{
+		{public, []string{"/users"}, []string{"audienceUser"}, []string{"name"}},
+		{internal, []string{"/users", "/stats"}, []string{"audienceUser", "audienceCredentials", "audienceStats"}, []string{"name", "note", "credentials"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.document.name(), func(t *testing.T) {
+			doc := tt.document.OpenAPI
+			if len(doc.Paths) != len(tt.paths) {
+				t.Errorf("got %d paths, want %v", len(doc.Paths), tt.paths)
+			}
+			for _, path := range tt.paths {
+				if doc.Paths[path] == nil {
+					t.Errorf("path %s is missing", path)
+				}
+			}
+			if len(doc.Components.Schemas) != len(tt.schemas) {
+				t.Errorf("got schemas %v, want %v", sortedKeys(doc.Components.Schemas), tt.schemas)
+			}
+			for _, name := range tt.schemas {
+				if doc.Components.Schemas[name] == nil {
+					t.Errorf("schema %s is missing", name)
+				}
+			}
+			properties := doc.Components.Schemas["audienceUser"].Value.Properties
+			if len(properties) != len(tt.properties) {
+				t.Errorf("got properties %v, want %v", sortedKeys(properties), tt.properties)
+			}
+			for _, name := range tt.properties {
+				if properties[name] == nil {
+					t.Errorf("property %s is missing", name)
+				}
+			}
+		})
+	}
+}
@@ -3,37 +3,127 @@ package egs
 import (
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Yuukirn/egs/router"
+	"github.com/Yuukirn/egs/security"
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
 	"html/template"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 //go:embed templates/*
 var templates embed.FS
 
-type RouterMap map[*gin.RouterGroup]map[string]map[string]*router.Router
+// docsTemplates render the docs pages without touching the templates of the engine
+var docsTemplates = template.Must(template.ParseFS(templates, "templates/*.html"))
+
+// RouterMap keeps the registered routers in registration order, so routes are
+// registered to gin and documented in the same order on every run
+type RouterMap struct {
+	routers []*router.Router
+	// groups are the groups the routers are registered to
+	groups map[*router.Router]*Group
+}
+
+func NewRouterMap() *RouterMap {
+	return &RouterMap{groups: make(map[*router.Router]*Group)}
+}
+
+// Add appends r, returning an error if it conflicts with a registered router
+func (m *RouterMap) Add(r *router.Router) error {
+	for _, registered := range m.routers {
+		if err := checkConflict(r.Method, r.Path, registered.Method, registered.Path); err != nil {
+			return err
+		}
+	}
+	m.routers = append(m.routers, r)
+	return nil
+}
+
+// Get returns the router registered with path and method, or nil
+func (m *RouterMap) Get(path, method string) *router.Router {
+	for _, r := range m.routers {
+		if r.Path == path && r.Method == method {
+			return r
+		}
+	}
+	return nil
+}
+
+// Group returns the group r is registered to, or nil if r is registered to the engine
+func (m *RouterMap) Group(r *router.Router) *Group {
+	return m.groups[r]
+}
+
+// Routers returns the registered routers in registration order
+func (m *RouterMap) Routers() []*router.Router {
+	return m.routers
+}
 
 type Egs struct {
 	*gin.Engine
 
 	// Swagger is used to construct swagger json
 	Swagger *Swagger
+	// Documents are the documents added by Document, served next to Swagger
+	Documents []*Swagger
+
+	Routers *RouterMap
+
+	// errs are the registration errors returned by Build
+	errs []error
+	// middlewares added by Use, applied to the routers when mounted into another app
+	middlewares []gin.HandlerFunc
+
+	autoOptions      bool
+	methodNotAllowed bool
+	cors             *CORSConfig
+	// generated are the routers generated from the options, like automatic OPTIONS routers
+	generated []*router.Router
 
-	Routers RouterMap
+	initOnce sync.Once
+	initErr  error
+
+	// engineMiddlewares replace the middlewares of gin.Default()
+	engineMiddlewares []gin.HandlerFunc
+
+	// lifecycle
+	startupHooks    []Hook
+	shutdownHooks   []Hook
+	shutdownTimeout time.Duration
+	shutdownSignals []os.Signal
 }
 
-func New(swagger *Swagger) *Egs {
-	engine := gin.Default()
+// New returns an app on gin.Default(), unless an engine or middlewares are given by options
+func New(swagger *Swagger, options ...Option) *Egs {
 	egs := &Egs{
-		Engine:  engine,
-		Swagger: swagger,
-		Routers: make(RouterMap),
+		Swagger:         swagger,
+		Routers:         NewRouterMap(),
+		shutdownTimeout: 10 * time.Second,
+		shutdownSignals: []os.Signal{os.Interrupt, syscall.SIGTERM},
+	}
+
+	for _, option := range options {
+		option(egs)
 	}
-	egs.Routers[&egs.RouterGroup] = make(map[string]map[string]*router.Router)
 
-	egs.SetHTMLTemplate(template.Must(template.ParseFS(templates, "templates/*.html")))
+	if egs.Engine == nil {
+		if egs.engineMiddlewares != nil {
+			egs.Engine = gin.New()
+			egs.Engine.Use(egs.engineMiddlewares...)
+		} else {
+			egs.Engine = gin.Default()
+		}
+	}
 
 	// set swagger router
 	if swagger != nil {
@@ -44,14 +134,33 @@ func New(swagger *Swagger) *Egs {
 }
 
 func (e *Egs) Use(middlewares ...gin.HandlerFunc) gin.IRoutes {
+	e.middlewares = append(e.middlewares, middlewares...)
 	return e.Engine.Use(middlewares...)
 }
 
+// Mount registers the routers of sub under prefix, behind the middlewares added
+// by sub.Use, so modules can be built as their own apps and served by one engine
+// with one document. Routers registered to sub after mounting are not included.
+//
+// Route conflicts and registration errors of sub are returned by Build, as are
+// schemas and security schemes of sub colliding with the ones of e.
+func (e *Egs) Mount(prefix string, sub *Egs, options ...GroupOption) *Group {
+	group := e.Group(prefix, options...)
+	group.Use(sub.middlewares...)
+
+	for _, err := range sub.errs {
+		e.errs = append(e.errs, fmt.Errorf("mount %s: %w", prefix, err))
+	}
+	for _, r := range sub.Routers.Routers() {
+		group.handle(r.Method, r.Path, r)
+	}
+	return group
+}
+
 func (e *Egs) Group(path string, options ...GroupOption) *Group {
 	group := &Group{
-		Egs:         e,
-		RouterGroup: e.Engine.Group(path),
-		Path:        path,
+		Egs:  e,
+		Path: path,
 	}
 
 	for _, option := range options {
@@ -61,54 +170,199 @@ func (e *Egs) Group(path string, options ...GroupOption) *Group {
 	return group
 }
 
-func (e *Egs) handle(path, method string, r *router.Router) {
+// Document adds a document of the routers selected by filters, served at its own
+// urls, like a document per API version. The documents are listed in the Swagger UI
+// of each other.
+func (e *Egs) Document(swagger *Swagger, filters ...RouteFilter) *Swagger {
+	swagger.Filters = append(swagger.Filters, filters...)
+	swagger.Routers = e.Routers
+	e.Documents = append(e.Documents, swagger)
+	return swagger
+}
+
+// documents returns Swagger followed by Documents
+func (e *Egs) documents() []*Swagger {
+	var documents []*Swagger
+	if e.Swagger != nil {
+		documents = append(documents, e.Swagger)
+	}
+	return append(documents, e.Documents...)
+}
+
+// handle registers r to group, which is nil for routers registered to the engine
+func (e *Egs) handle(group *Group, path, method string, r *router.Router) {
+	path = joinPaths("/", path)
+	if err := checkPathParams(path, r.Model); err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s %s: %w", method, path, err))
+		return
+	}
+	if err := security.Validate(r.Securities); err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s %s: %w", method, path, err))
+		return
+	}
+
+	// the same router may be registered for several paths and methods
+	r = r.Clone()
 	r.Method = method
 	r.Path = path
 
-	if e.Routers[&e.RouterGroup][path] == nil {
-		e.Routers[&e.RouterGroup][path] = make(map[string]*router.Router)
+	if err := e.Routers.Add(r); err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s %s: %w", method, path, err))
+		return
+	}
+	if group != nil {
+		e.Routers.groups[r] = group
 	}
+}
 
-	e.Routers[&e.RouterGroup][path][method] = r
+// anyMethods are the methods registered by gin's RouterGroup.Any
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// routerForMethod returns r for one of count methods it is registered for.
+// An explicit operationId is suffixed with the method to keep it unique.
+func routerForMethod(r *router.Router, method string, count int) *router.Router {
+	if count < 2 || r.OperationID == "" {
+		return r
+	}
+	r = r.Clone()
+	r.OperationID += camelCase(strings.ToLower(method))
+	return r
 }
 
 func (e *Egs) GET(path string, r *router.Router) {
-	e.handle(path, http.MethodGet, r)
+	e.handle(nil, path, http.MethodGet, r)
 }
 
 func (e *Egs) POST(path string, r *router.Router) {
-	e.handle(path, http.MethodPost, r)
+	e.handle(nil, path, http.MethodPost, r)
 }
 
 func (e *Egs) HEAD(path string, r *router.Router) {
-	e.handle(path, http.MethodHead, r)
+	e.handle(nil, path, http.MethodHead, r)
 }
 
 func (e *Egs) PUT(path string, r *router.Router) {
-	e.handle(path, http.MethodPut, r)
+	e.handle(nil, path, http.MethodPut, r)
 }
 
 func (e *Egs) DELETE(path string, r *router.Router) {
-	e.handle(path, http.MethodDelete, r)
+	e.handle(nil, path, http.MethodDelete, r)
 }
 
 func (e *Egs) PATCH(path string, r *router.Router) {
-	e.handle(path, http.MethodPatch, r)
+	e.handle(nil, path, http.MethodPatch, r)
 }
 
 func (e *Egs) OPTIONS(path string, r *router.Router) {
-	e.handle(path, http.MethodOptions, r)
+	e.handle(nil, path, http.MethodOptions, r)
 }
 
-func (e *Egs) init() {
-	e.initRouters()
+// Any registers r for all the methods gin's Any registers, each documented as its own operation
+func (e *Egs) Any(path string, r *router.Router) {
+	e.Match(anyMethods, path, r)
+}
+
+// Match registers r for each of methods, each documented as its own operation
+func (e *Egs) Match(methods []string, path string, r *router.Router) {
+	for _, method := range methods {
+		e.handle(nil, path, method, routerForMethod(r, method, len(methods)))
+	}
+}
+
+// Build validates the registered routers and builds the OpenAPI document without
+// registering anything to gin, so misconfigurations surface in unit tests.
+// All registration errors are returned together. The document of Swagger is
+// returned, the ones of Documents are set on them.
+func (e *Egs) Build() (*openapi3.T, error) {
+	if err := errors.Join(e.errs...); err != nil {
+		return nil, err
+	}
+	e.generated = e.autoOptionsRouters()
+
+	documents := e.documents()
+	if err := checkDocumentUrls(documents); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, swagger := range documents {
+		swagger.generated = e.generated
+		swagger.methodNotAllowed = e.methodNotAllowed
+		if err := swagger.BuildOpenAPI(); err != nil {
+			if len(documents) > 1 {
+				err = fmt.Errorf("document %s: %w", swagger.name(), err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
 	if e.Swagger == nil {
-		return
+		return nil, nil
 	}
-	gin.DisableBindValidation()
-	e.Engine.GET(e.Swagger.OpenAPIUrl, func(c *gin.Context) {
-		if strings.HasSuffix(e.Swagger.OpenAPIUrl, ".yml") || strings.HasSuffix(e.Swagger.OpenAPIUrl, ".yaml") {
-			yaml, err := e.Swagger.MarshalYaml()
+	return e.Swagger.OpenAPI, nil
+}
+
+// MustBuild is like Build but panics on errors
+func (e *Egs) MustBuild() *openapi3.T {
+	doc, err := e.Build()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// WriteSpec builds the documents and writes the one of Swagger to filename, as
+// YAML if it ends with `.yml` or `.yaml` and as indented JSON otherwise, so the
+// spec can be generated in CI without starting the server
+func (e *Egs) WriteSpec(filename string) error {
+	if e.Swagger == nil {
+		return errors.New("egs: no swagger to write")
+	}
+	if _, err := e.Build(); err != nil {
+		return err
+	}
+	return e.Swagger.WriteFile(filename)
+}
+
+// Handler registers the routers and the documents to gin and returns the engine,
+// so the app can be served by an http.Server or tested with httptest
+func (e *Egs) Handler() (http.Handler, error) {
+	if err := e.init(); err != nil {
+		return nil, err
+	}
+	return e.Engine, nil
+}
+
+// init registers everything to gin once, later calls return the error of the first one
+func (e *Egs) init() error {
+	e.initOnce.Do(func() {
+		e.initErr = e.register()
+	})
+	return e.initErr
+}
+
+func (e *Egs) register() error {
+	if _, err := e.Build(); err != nil {
+		return err
+	}
+	e.initRouters()
+	documents := e.documents()
+	for _, swagger := range documents {
+		e.serveDocument(swagger, documents)
+	}
+	return nil
+}
+
+// serveDocument registers the urls of swagger, the Swagger UI lists all the documents
+func (e *Egs) serveDocument(swagger *Swagger, documents []*Swagger) {
+	e.Engine.GET(swagger.OpenAPIUrl, func(c *gin.Context) {
+		if isYaml(swagger.OpenAPIUrl) {
+			yaml, err := swagger.MarshalYaml()
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": err.Error(),
@@ -117,78 +371,106 @@ func (e *Egs) init() {
 			}
 			c.String(http.StatusOK, string(yaml))
 		} else {
-			c.JSON(http.StatusOK, e.Swagger)
+			c.JSON(http.StatusOK, swagger)
 		}
 	})
 
-	e.Engine.GET(e.Swagger.DocsUrl, func(c *gin.Context) {
-		options := "{}"
-		if e.Swagger.SwaggerOptions != nil {
-			bytes, err := json.Marshal(e.Swagger.SwaggerOptions)
+	if swagger.Swagger2Url != "" {
+		e.Engine.GET(swagger.Swagger2Url, func(c *gin.Context) {
+			if isYaml(swagger.Swagger2Url) {
+				yaml, err := swagger.MarshalSwagger2Yaml()
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": err.Error(),
+					})
+					return
+				}
+				c.String(http.StatusOK, string(yaml))
+				return
+			}
+			bytes, err := swagger.MarshalSwagger2()
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
-			options = string(bytes)
-		}
-		c.HTML(http.StatusOK, "swagger.html", gin.H{
-			"openapi_url":     e.Swagger.OpenAPIUrl,
-			"title":           e.Swagger.Title,
-			"swagger_options": options,
+			c.Data(http.StatusOK, binding.MIMEJSON, bytes)
 		})
-	})
+	}
 
-	e.Engine.GET(e.Swagger.RedocUrl, func(c *gin.Context) {
-		options := "{}"
-		if e.Swagger.RedocOptions != nil {
-			bytes, err := json.Marshal(e.Swagger.RedocOptions)
+	if swagger.DocsUrl != "" {
+		e.Engine.GET(swagger.DocsUrl, func(c *gin.Context) {
+			bytes, err := json.Marshal(swagger.swaggerUIOptions(documents))
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
-			options = string(bytes)
-		}
-		c.HTML(http.StatusOK, "redoc.html", gin.H{
-			"openapi_url":   e.Swagger.OpenAPIUrl,
-			"title":         e.Swagger.Title,
-			"redoc_options": options,
+			oauth, err := json.Marshal(swagger.OAuth)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			renderDocs(c, "swagger.html", gin.H{
+				"openapi_url":         swagger.OpenAPIUrl,
+				"title":               swagger.Title,
+				"swagger_options":     string(bytes),
+				"oauth":               string(oauth),
+				"oauth2_redirect_url": swagger.oauth2RedirectUrl(),
+			})
 		})
-	})
-
-	e.Swagger.BuildOpenAPI()
-}
+		e.Engine.GET(swagger.oauth2RedirectUrl(), func(c *gin.Context) {
+			renderDocs(c, "oauth2-redirect.html", nil)
+		})
+	}
 
-func (e *Egs) initRouters() {
-	for group, routers := range e.Routers {
-		for path, m := range routers {
-			for method, r := range m {
-				handlers := r.GetHandlers()
-				switch method {
-				case http.MethodGet:
-					group.GET(path, handlers...)
-				case http.MethodPost:
-					group.POST(path, handlers...)
-				case http.MethodPut:
-					group.PUT(path, handlers...)
-				case http.MethodDelete:
-					group.DELETE(path, handlers...)
-				case http.MethodOptions:
-					group.OPTIONS(path, handlers...)
-				case http.MethodHead:
-					group.OPTIONS(path, handlers...)
-				default:
-					group.Any(path, handlers...)
+	if swagger.RedocUrl != "" {
+		e.Engine.GET(swagger.RedocUrl, func(c *gin.Context) {
+			options := "{}"
+			if swagger.RedocOptions != nil {
+				bytes, err := json.Marshal(swagger.RedocOptions)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": err.Error(),
+					})
+					return
 				}
+				options = string(bytes)
 			}
-		}
+			renderDocs(c, "redoc.html", gin.H{
+				"openapi_url":   swagger.OpenAPIUrl,
+				"title":         swagger.Title,
+				"redoc_options": options,
+			})
+		})
 	}
 }
 
-func (e *Egs) Run(addr ...string) error {
-	e.init()
-	return e.Engine.Run(addr...)
+func renderDocs(c *gin.Context, name string, data gin.H) {
+	c.Render(http.StatusOK, render.HTML{
+		Template: docsTemplates,
+		Name:     name,
+		Data:     data,
+	})
+}
+
+func (e *Egs) initRouters() {
+	if e.cors != nil {
+		e.Engine.Use(e.cors.middleware)
+	}
+	if e.methodNotAllowed {
+		e.Engine.HandleMethodNotAllowed = true
+		e.Engine.NoMethod(e.noMethod)
+	}
+
+	for _, r := range e.Routers.Routers() {
+		e.Engine.Handle(r.Method, r.Path, handlers(r)...)
+	}
+	for _, r := range e.generated {
+		e.Engine.Handle(r.Method, r.Path, r.GetHandlers()...)
+	}
 }
@@ -0,0 +1,205 @@
+package egs
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/Yuukirn/egs/security"
+	"github.com/gin-gonic/gin"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// checkGolden compares got with testdata/name, which is rewritten with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, run `go test -update` after checking the output:\n%s", path, got)
+	}
+}
+
+func TestAnyRegistersEveryMethod(t *testing.T) {
+	tests := []struct {
+		method      string
+		operationID string
+	}{
+		{http.MethodGet, "itemGet"},
+		{http.MethodPost, "itemPost"},
+		{http.MethodPut, "itemPut"},
+		{http.MethodPatch, "itemPatch"},
+		{http.MethodHead, "itemHead"},
+		{http.MethodOptions, "itemOptions"},
+		{http.MethodDelete, "itemDelete"},
+		{http.MethodConnect, "itemConnect"},
+		{http.MethodTrace, "itemTrace"},
+	}
+	if len(tests) != len(anyMethods) {
+		t.Fatalf("%d methods tested, Any registers %d", len(tests), len(anyMethods))
+	}
+
+	item := router.NewRouterX(func(c *gin.Context) {
+		c.Header("X-Method", c.Request.Method)
+	}, router.OperationID("item"))
+	app := New(NewSwagger("any", "", "1.0.0"))
+	app.Any("/items", item)
+	app.Group("/v1").Any("/items", router.NewRouterX(item.API))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathItem := app.Swagger.OpenAPI.Paths["/items"]
+	groupPathItem := app.Swagger.OpenAPI.Paths["/v1/items"]
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			for _, path := range []string{"/items", "/v1/items"} {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(tt.method, path, nil))
+				if w.Code != http.StatusOK || w.Header().Get("X-Method") != tt.method {
+					t.Errorf("%s %s: got %d handled as %q", tt.method, path, w.Code, w.Header().Get("X-Method"))
+				}
+			}
+
+			operation := pathItem.GetOperation(tt.method)
+			if operation == nil {
+				t.Fatalf("%s /items is not documented", tt.method)
+			}
+			if operation.OperationID != tt.operationID {
+				t.Errorf("operationId = %q, want %q", operation.OperationID, tt.operationID)
+			}
+			if groupPathItem.GetOperation(tt.method) == nil {
+				t.Errorf("%s /v1/items is not documented", tt.method)
+			}
+		})
+	}
+}
+
+func TestMethodRegistration(t *testing.T) {
+	tests := []struct {
+		method   string
+		register func(e *Egs, path string, r *router.Router)
+	}{
+		{http.MethodGet, (*Egs).GET},
+		{http.MethodPost, (*Egs).POST},
+		{http.MethodPut, (*Egs).PUT},
+		{http.MethodPatch, (*Egs).PATCH},
+		{http.MethodHead, (*Egs).HEAD},
+		{http.MethodOptions, (*Egs).OPTIONS},
+		{http.MethodDelete, (*Egs).DELETE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			app := New(NewSwagger("method", "", "1.0.0"))
+			tt.register(app, "/items", router.NewRouterX(func(c *gin.Context) {
+				c.Header("X-Method", c.Request.Method)
+			}, router.OperationID("item")))
+			handler, err := app.Handler()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, "/items", nil))
+			if w.Code != http.StatusOK || w.Header().Get("X-Method") != tt.method {
+				t.Errorf("got %d handled as %q", w.Code, w.Header().Get("X-Method"))
+			}
+			// a single method keeps the operationId as is
+			operation := app.Swagger.OpenAPI.Paths["/items"].GetOperation(tt.method)
+			if operation == nil || operation.OperationID != "item" {
+				t.Errorf("operation = %+v, want operationId item", operation)
+			}
+		})
+	}
+}
+
+type mountItem struct {
+	Name string `json:"name"`
+}
+
+func TestMount(t *testing.T) {
+	sub := New(NewSwagger("users", "", "1.0.0"), Middlewares())
+	sub.Use(mark("sub"))
+	users := sub.Group("/users", Handlers(mark("sub group")))
+	users.GET("/:id", router.NewRouterX(func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	}, router.Tags("users")))
+
+	app := New(NewSwagger("app", "", "1.0.0"), Middlewares())
+	app.Use(mark("engine"))
+	app.Mount("/api", sub, Handlers(mark("parent group")))
+
+	// the prefix is added to the paths of sub
+	if _, ok := app.MustBuild().Paths["/api/users/{id}"]; !ok {
+		t.Error("/api/users/{id} isn't documented")
+	}
+	w := serve(t, app, http.MethodGet, "/api/users/1")
+	if w.Code != http.StatusOK || w.Body.String() != "1" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+	want := []string{"engine", "parent group", "sub", "sub group"}
+	if got := w.Header().Values("X-Order"); !reflect.DeepEqual(got, want) {
+		t.Errorf("handlers ran in order %v, want %v", got, want)
+	}
+}
+
+func TestMountErrors(t *testing.T) {
+	response := func(model any) router.Option {
+		return router.Resp(router.Response{"200": router.ResponseItem{Model: model}})
+	}
+	api := func(c *gin.Context) {}
+	mount := func(sub *Egs) error {
+		app := New(NewSwagger("app", "", "1.0.0"))
+		app.GET("/items", router.NewRouterX(api, response(&mountItem{}),
+			router.Security(&security.Basic{AuthName: "auth"})))
+		app.Mount("/sub", sub)
+		_, err := app.Build()
+		return err
+	}
+	// a type named like the package mountItem, from another package in real apps
+	type mountItem struct {
+		ID int `json:"id"`
+	}
+
+	sub := New(NewSwagger("sub", "", "1.0.0"))
+	sub.GET("/items/:key", router.NewRouter(func(c *gin.Context, req pathTestReq) {}))
+	if err := mount(sub); err == nil || !strings.Contains(err.Error(), "mount /sub: GET /items/:key: uri field \"id\"") {
+		t.Errorf("err = %v, want the error of sub", err)
+	}
+
+	sub = New(NewSwagger("sub", "", "1.0.0"))
+	sub.GET("/items", router.NewRouterX(api, response(&mountItem{}),
+		router.Security(&security.Bearer{AuthName: "auth"})))
+	err := mount(sub)
+	for _, want := range []string{
+		`schema "mountItem" is generated from both`,
+		`security scheme "auth" is declared with different schemas`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// Code generated by egsdoc. DO NOT EDIT.
+
+package main
+
+import "github.com/Yuukirn/egs"
+
+func init() {
+	egs.RegisterDocs(egs.Docs{
+		Types: map[string]string{
+			"main.TestResp":   "TestResp is the common response",
+			"main.TestStruct": "TestStruct is the request of the test api",
+		},
+		Fields: map[string]string{
+			"main.TestResp.Code": "Code is the business status code",
+			"main.TestResp.Msg":  "human readable message",
+		},
+		Funcs: map[string]string{
+			"main.TestApi": "TestApi echoes the test request.\n\nThe request is printed and an empty response is returned.",
+		},
+	})
+}
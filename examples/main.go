@@ -1,7 +1,10 @@
+//go:generate go run github.com/Yuukirn/egs/cmd/egsdoc -o egs_docs.go
+
 package main
 
 import (
 	"encoding/json"
+	"flag"
 	"github.com/Yuukirn/egs"
 	"github.com/Yuukirn/egs/router"
 	"github.com/Yuukirn/egs/security"
@@ -13,6 +16,7 @@ var ping = router.NewRouterX(func(c *gin.Context) {
 	c.String(http.StatusOK, "pong")
 })
 
+// TestStruct is the request of the test api
 type TestStruct struct {
 	ID    string `uri:"id" validate:"required" json:"id"`
 	Name  string `form:"name" json:"name"`
@@ -20,12 +24,17 @@ type TestStruct struct {
 	Token string `header:"authorization" validate:"required" json:"token"`
 }
 
+// TestResp is the common response
 type TestResp struct {
+	// Code is the business status code
 	Code int    `json:"code"`
-	Msg  string `json:"msg"`
+	Msg  string `json:"msg"` // human readable message
 	Data any    `json:"data"`
 }
 
+// TestApi echoes the test request.
+//
+// The request is printed and an empty response is returned.
 func TestApi(c *gin.Context, req TestStruct) {
 	bytes, err := json.Marshal(req)
 	if err != nil {
@@ -65,6 +74,9 @@ func testMiddleware(c *gin.Context) {
 }
 
 func main() {
+	spec := flag.String("spec", "", "write the spec to the file and exit, like openapi.json or openapi.yaml")
+	flag.Parse()
+
 	app := egs.New(egs.NewSwagger("example", "", "3.0.0"))
 	app.GET("/ping", ping)
 
@@ -73,5 +85,14 @@ func main() {
 		testGroup.POST("/:id", test)
 	}
 
-	app.Run(":8080")
+	if *spec != "" {
+		if err := app.WriteSpec(*spec); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if err := app.Run(":8080"); err != nil {
+		panic(err)
+	}
 }
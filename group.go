@@ -7,15 +7,36 @@ import (
 	"net/http"
 )
 
+// Group shares a path prefix, tags, middlewares, securities and router defaults
+// between routers. Like gin, the settings of a group apply to the routers
+// registered to it and to the groups created from it afterwards.
+//
+// Router settings take precedence over group settings, and inner groups over outer ones:
+//   - Response entries of the router replace group entries with the same status code
+//   - Deprecated and Exclude apply if set on the router or any group
+//   - SummaryPrefix of the groups, outer first, is prepended to the router summary
+//   - RequestContentType and ResponseContentType are used when the router has none
 type Group struct {
-	Egs         *Egs
-	Path        string
-	Tags        []string
-	RouterGroup *gin.RouterGroup
+	Egs  *Egs
+	Path string
+	Tags []string
+	// Audiences label the routers, see router.Audience
+	Audiences []string
 
 	// middlewares
 	Handlers   []gin.HandlerFunc
 	Securities []security.Security
+
+	// router defaults
+	Response            router.Response
+	Deprecated          bool
+	Exclude             bool
+	SummaryPrefix       string
+	RequestContentType  string
+	ResponseContentType string
+
+	// parent is the group g was created from, used to select routers by group
+	parent *Group
 }
 
 type GroupOption func(group *Group)
@@ -32,21 +53,113 @@ func Tags(tags string) GroupOption {
 	}
 }
 
+// Audience labels every router, so they are only documented for documents including one of audiences
+func Audience(audiences ...string) GroupOption {
+	return func(g *Group) {
+		g.Audiences = append(g.Audiences, audiences...)
+	}
+}
+
 func Security(securities ...security.Security) GroupOption {
 	return func(g *Group) {
 		g.Securities = append(g.Securities, securities...)
 	}
 }
 
-func (g *Group) Use(middleware ...gin.HandlerFunc) gin.IRoutes {
-	return g.RouterGroup.Use(middleware...)
+// Resp adds default responses to every router, like common 401/403/500 responses
+func Resp(response router.Response) GroupOption {
+	return func(g *Group) {
+		if g.Response == nil {
+			g.Response = make(router.Response)
+		}
+		for code, item := range response {
+			g.Response[code] = item
+		}
+	}
+}
+
+func Deprecated() GroupOption {
+	return func(g *Group) {
+		g.Deprecated = true
+	}
+}
+
+func Exclude() GroupOption {
+	return func(g *Group) {
+		g.Exclude = true
+	}
+}
+
+// SummaryPrefix is prepended to the summary of every router
+func SummaryPrefix(prefix string) GroupOption {
+	return func(g *Group) {
+		g.SummaryPrefix += prefix
+	}
+}
+
+func RequestContentType(contentType string) GroupOption {
+	return func(g *Group) {
+		g.RequestContentType = contentType
+	}
+}
+
+func ResponseContentType(contentType string) GroupOption {
+	return func(g *Group) {
+		g.ResponseContentType = contentType
+	}
+}
+
+// Use adds middlewares which run after the middlewares of the engine and of the
+// parent groups, and before the handlers of the router
+func (g *Group) Use(middleware ...gin.HandlerFunc) *Group {
+	g.Handlers = append(g.Handlers, middleware...)
+	return g
 }
 
 func (g *Group) handle(method, path string, r *router.Router) {
-	r.Handlers = append(r.Handlers, g.Handlers...)
+	r = r.Clone()
+	r.Handlers = append(append([]gin.HandlerFunc(nil), g.Handlers...), r.Handlers...)
 	r.Tags = append(r.Tags, g.Tags...)
-	r.Securities = append(r.Securities, g.Securities...)
-	g.Egs.handle(g.Path+path, method, r)
+	r.Audiences = append(r.Audiences, g.Audiences...)
+	if !r.Public {
+		r.Securities = append(r.Securities, g.Securities...)
+	}
+	g.applyDefaults(r)
+	g.Egs.handle(g, joinPaths(g.Path, path), method, r)
+}
+
+// applyDefaults applies the router defaults of the group to the cloned router r
+func (g *Group) applyDefaults(r *router.Router) {
+	if len(g.Response) != 0 {
+		response := make(router.Response, len(g.Response)+len(r.Response))
+		for code, item := range g.Response {
+			response[code] = item
+		}
+		for code, item := range r.Response {
+			response[code] = item
+		}
+		r.Response = response
+	}
+
+	r.Deprecated = r.Deprecated || g.Deprecated
+	r.Exclude = r.Exclude || g.Exclude
+
+	if g.SummaryPrefix != "" {
+		summary := r.Summary
+		if summary == "" {
+			summary, _ = splitDoc(funcDoc(r.HandlerName))
+		}
+		if summary != "" {
+			r.Summary = g.SummaryPrefix + summary
+		}
+	}
+
+	if r.RequestContentType == "" {
+		r.RequestContentType = g.RequestContentType
+	}
+	if r.ResponseContentType == "" {
+		r.ResponseContentType = g.ResponseContentType
+	}
 }
 
 func (g *Group) GET(path string, r *router.Router) {
@@ -77,14 +190,39 @@ func (g *Group) PUT(path string, r *router.Router) {
 	g.handle(http.MethodPut, path, r)
 }
 
+// Any registers r for all the methods gin's Any registers, each documented as its own operation
+func (g *Group) Any(path string, r *router.Router) {
+	g.Match(anyMethods, path, r)
+}
+
+// Match registers r for each of methods, each documented as its own operation
+func (g *Group) Match(methods []string, path string, r *router.Router) {
+	for _, method := range methods {
+		g.handle(method, path, routerForMethod(r, method, len(methods)))
+	}
+}
+
 func (g *Group) Group(path string, options ...GroupOption) *Group {
+	// copy the settings, so appending to them doesn't leak into the parent or siblings
 	group := &Group{
-		Egs:         g.Egs,
-		Path:        g.Path + path,
-		Tags:        g.Tags,
-		RouterGroup: g.RouterGroup.Group(path),
-		Handlers:    g.Handlers,
-		Securities:  g.Securities,
+		Egs:        g.Egs,
+		Path:       joinPaths(g.Path, path),
+		Tags:       append([]string(nil), g.Tags...),
+		Audiences:  append([]string(nil), g.Audiences...),
+		Handlers:   append([]gin.HandlerFunc(nil), g.Handlers...),
+		Securities: append([]security.Security(nil), g.Securities...),
+
+		Response:            make(router.Response, len(g.Response)),
+		Deprecated:          g.Deprecated,
+		Exclude:             g.Exclude,
+		SummaryPrefix:       g.SummaryPrefix,
+		RequestContentType:  g.RequestContentType,
+		ResponseContentType: g.ResponseContentType,
+
+		parent: g,
+	}
+	for code, item := range g.Response {
+		group.Response[code] = item
 	}
 
 	for _, option := range options {
@@ -0,0 +1,186 @@
+package egs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/Yuukirn/egs/security"
+	"github.com/gin-gonic/gin"
+)
+
+// mark adds name to the `X-Order` header, recording the order the handlers run in
+func mark(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("X-Order", name)
+	}
+}
+
+func serve(t *testing.T, app *Egs, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestGroupMiddlewareOrder(t *testing.T) {
+	app := New(NewSwagger("group", "", "1.0.0"), Middlewares())
+	app.Use(mark("engine"))
+	outer := app.Group("/outer", Handlers(mark("outer")))
+	inner := outer.Group("/inner", Handlers(mark("inner")))
+	r := router.NewRouterX(mark("api"))
+	r.Handlers = append(r.Handlers, mark("router"))
+	inner.GET("/items", r)
+
+	w := serve(t, app, http.MethodGet, "/outer/inner/items")
+	want := []string{"engine", "outer", "inner", "router", "api"}
+	if got := w.Header().Values("X-Order"); !reflect.DeepEqual(got, want) {
+		t.Errorf("handlers ran in order %v, want %v", got, want)
+	}
+}
+
+func TestGroupSiblingIsolation(t *testing.T) {
+	parentAuth := &security.Basic{AuthName: "parent"}
+	aAuth := &security.Basic{AuthName: "a"}
+	bAuth := &security.Basic{AuthName: "b"}
+
+	app := New(NewSwagger("group", "", "1.0.0"), Middlewares())
+	parent := app.Group("/parent", Tags("parent"), Handlers(mark("parent")), Security(parentAuth))
+	a := parent.Group("/a", Tags("a"), Security(aAuth))
+	a.Use(mark("a"))
+	b := parent.Group("/b", Tags("b"), Security(bAuth))
+	b.Use(mark("b"))
+	// appending again must not overwrite what was appended to the sibling
+	a.Use(mark("a2"))
+
+	api := func(c *gin.Context) {}
+	parent.GET("/items", router.NewRouterX(api))
+	a.GET("/items", router.NewRouterX(api))
+	b.GET("/items", router.NewRouterX(api))
+
+	if len(parent.Tags) != 1 || len(parent.Handlers) != 1 || len(parent.Securities) != 1 {
+		t.Errorf("parent grew: tags %v, %d handlers, %d securities", parent.Tags, len(parent.Handlers), len(parent.Securities))
+	}
+
+	tests := []struct {
+		path       string
+		tags       []string
+		handlers   []string
+		securities []string
+	}{
+		{"/parent/items", []string{"parent"}, []string{"parent"}, []string{"parent"}},
+		{"/parent/a/items", []string{"parent", "a"}, []string{"parent", "a", "a2"}, []string{"parent", "a"}},
+		{"/parent/b/items", []string{"parent", "b"}, []string{"parent", "b"}, []string{"parent", "b"}},
+	}
+	doc := app.MustBuild()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			operation := doc.Paths[tt.path].Get
+			if !reflect.DeepEqual(operation.Tags, tt.tags) {
+				t.Errorf("tags = %v, want %v", operation.Tags, tt.tags)
+			}
+			var securities []string
+			for _, requirement := range *operation.Security {
+				for name := range requirement {
+					securities = append(securities, name)
+				}
+			}
+			if strings.Join(securities, ",") != strings.Join(tt.securities, ",") {
+				t.Errorf("securities = %v, want %v", securities, tt.securities)
+			}
+
+			w := serve(t, app, http.MethodGet, tt.path)
+			if got := w.Header().Values("X-Order"); !reflect.DeepEqual(got, tt.handlers) {
+				t.Errorf("handlers = %v, want %v", got, tt.handlers)
+			}
+		})
+	}
+}
+
+func TestGroupDefaults(t *testing.T) {
+	item := func(description string) router.ResponseItem {
+		return router.ResponseItem{Description: description, Model: &goldenPet{}}
+	}
+	api := func(c *gin.Context) {}
+
+	app := New(NewSwagger("group", "", "1.0.0"))
+	outer := app.Group("/outer",
+		Resp(router.Response{"401": item("outer 401"), "500": item("outer 500")}),
+		SummaryPrefix("[outer] "),
+		RequestContentType("application/xml"),
+		ResponseContentType("application/xml"),
+	)
+	inner := outer.Group("/inner",
+		Resp(router.Response{"500": item("inner 500")}),
+		SummaryPrefix("[inner] "),
+		ResponseContentType("text/plain"),
+	)
+	inner.POST("/defaults", router.NewRouterX(api,
+		router.Summary("create"),
+		router.Req(router.Request{Model: &goldenPetReq{}}),
+		router.Resp(router.Response{"200": item("router 200"), "401": item("router 401")}),
+	))
+	inner.POST("/own", router.NewRouterX(api,
+		router.Req(router.Request{Model: &goldenPetReq{}}),
+		router.Resp(router.Response{"200": item("router 200")}),
+		router.RequestContentType("application/json"),
+		router.ResponseContentType("application/json"),
+	))
+	app.POST("/engine", router.NewRouterX(api,
+		router.Summary("create"),
+		router.Req(router.Request{Model: &goldenPetReq{}}),
+		router.Resp(router.Response{"200": item("router 200")}),
+	))
+	doc := app.MustBuild()
+
+	tests := []struct {
+		path         string
+		summary      string
+		responses    map[string]string
+		requestType  string
+		responseType string
+	}{
+		{
+			"/outer/inner/defaults", "[outer] [inner] create",
+			// router responses replace group responses with the same code, inner groups outer ones
+			map[string]string{"200": "router 200", "401": "router 401", "500": "inner 500"},
+			"application/xml", "text/plain",
+		},
+		{
+			"/outer/inner/own", "",
+			map[string]string{"200": "router 200", "401": "outer 401", "500": "inner 500"},
+			"application/json", "application/json",
+		},
+		{"/engine", "create", map[string]string{"200": "router 200"}, "application/json", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			operation := doc.Paths[tt.path].Post
+			if operation.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", operation.Summary, tt.summary)
+			}
+			responses := make(map[string]string)
+			for code, response := range operation.Responses {
+				if code != "default" {
+					responses[code] = *response.Value.Description
+				}
+			}
+			if !reflect.DeepEqual(responses, tt.responses) {
+				t.Errorf("responses = %v, want %v", responses, tt.responses)
+			}
+			if content := operation.RequestBody.Value.Content; content.Get(tt.requestType) == nil || len(content) != 1 {
+				t.Errorf("request content types = %v, want %s", sortedKeys(content), tt.requestType)
+			}
+			if content := operation.Responses["200"].Value.Content; content.Get(tt.responseType) == nil || len(content) != 1 {
+				t.Errorf("response content types = %v, want %s", sortedKeys(content), tt.responseType)
+			}
+		})
+	}
+}
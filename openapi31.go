@@ -0,0 +1,205 @@
+package egs
+
+import (
+	"encoding/json"
+)
+
+// jsonSchemaDialect is the default dialect of schemas in OpenAPI 3.1 documents
+const jsonSchemaDialect = "https://spec.openapis.org/oas/3.1/dialect/base"
+
+// marshalOpenAPI31 converts the generated 3.0 document to OpenAPI 3.1.
+// kin-openapi only models 3.0, so the conversion works on the decoded json.
+func (swagger *Swagger) marshalOpenAPI31() ([]byte, error) {
+	bytes, err := swagger.OpenAPI.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(bytes, &doc); err != nil {
+		return nil, err
+	}
+	doc["openapi"] = OpenAPI31
+	doc["jsonSchemaDialect"] = jsonSchemaDialect
+
+	if len(swagger.webhooks) != 0 {
+		bytes, err := json.Marshal(swagger.webhooks)
+		if err != nil {
+			return nil, err
+		}
+		var webhooks map[string]any
+		if err := json.Unmarshal(bytes, &webhooks); err != nil {
+			return nil, err
+		}
+		doc["webhooks"] = webhooks
+	}
+
+	if paths, ok := doc["paths"].(map[string]any); ok {
+		for _, pathItem := range paths {
+			convertPathItem31(pathItem)
+		}
+	}
+	if webhooks, ok := doc["webhooks"].(map[string]any); ok {
+		for _, pathItem := range webhooks {
+			convertPathItem31(pathItem)
+		}
+	}
+	if components, ok := doc["components"].(map[string]any); ok {
+		convertComponents31(components)
+	}
+
+	return json.Marshal(doc)
+}
+
+func convertComponents31(components map[string]any) {
+	if schemas, ok := components["schemas"].(map[string]any); ok {
+		for _, schema := range schemas {
+			convertSchema31(schema)
+		}
+	}
+	if parameters, ok := components["parameters"].(map[string]any); ok {
+		for _, parameter := range parameters {
+			convertSchemaHolder31(parameter)
+		}
+	}
+	if headers, ok := components["headers"].(map[string]any); ok {
+		for _, header := range headers {
+			convertSchemaHolder31(header)
+		}
+	}
+	if responses, ok := components["responses"].(map[string]any); ok {
+		for _, response := range responses {
+			convertResponse31(response)
+		}
+	}
+	if requestBodies, ok := components["requestBodies"].(map[string]any); ok {
+		for _, requestBody := range requestBodies {
+			convertContent31(requestBody)
+		}
+	}
+}
+
+func convertPathItem31(v any) {
+	pathItem, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	convertParameters31(pathItem["parameters"])
+	for _, method := range []string{"get", "put", "post", "delete", "options", "head", "patch", "trace", "connect"} {
+		operation, ok := pathItem[method].(map[string]any)
+		if !ok {
+			continue
+		}
+		convertParameters31(operation["parameters"])
+		convertContent31(operation["requestBody"])
+		if responses, ok := operation["responses"].(map[string]any); ok {
+			for _, response := range responses {
+				convertResponse31(response)
+			}
+		}
+	}
+}
+
+func convertParameters31(v any) {
+	parameters, ok := v.([]any)
+	if !ok {
+		return
+	}
+	for _, parameter := range parameters {
+		convertSchemaHolder31(parameter)
+	}
+}
+
+func convertResponse31(v any) {
+	response, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	convertContent31(response)
+	if headers, ok := response["headers"].(map[string]any); ok {
+		for _, header := range headers {
+			convertSchemaHolder31(header)
+		}
+	}
+}
+
+// convertContent31 converts the schemas of a request body or response content
+func convertContent31(v any) {
+	holder, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	content, ok := holder["content"].(map[string]any)
+	if !ok {
+		return
+	}
+	for _, mediaType := range content {
+		convertSchemaHolder31(mediaType)
+	}
+}
+
+// convertSchemaHolder31 converts the schema of a parameter, header or media type.
+// The `example` of the holder itself is still valid in 3.1 and is kept.
+func convertSchemaHolder31(v any) {
+	holder, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	convertSchema31(holder["schema"])
+	convertContent31(holder)
+}
+
+// convertSchema31 rewrites 3.0 only keywords to their JSON Schema 2020-12 form:
+// `nullable` becomes a `null` type union, `example` becomes `examples` and
+// boolean `exclusiveMinimum`/`exclusiveMaximum` become numbers.
+func convertSchema31(v any) {
+	schema, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+
+	if nullable, ok := schema["nullable"].(bool); ok {
+		delete(schema, "nullable")
+		if typ, ok := schema["type"].(string); ok && nullable {
+			schema["type"] = []any{typ, "null"}
+		}
+	}
+
+	if example, ok := schema["example"]; ok {
+		delete(schema, "example")
+		if _, ok := schema["examples"]; !ok {
+			schema["examples"] = []any{example}
+		}
+	}
+
+	if exclusive, ok := schema["exclusiveMinimum"].(bool); ok {
+		delete(schema, "exclusiveMinimum")
+		if min, ok := schema["minimum"]; ok && exclusive {
+			delete(schema, "minimum")
+			schema["exclusiveMinimum"] = min
+		}
+	}
+	if exclusive, ok := schema["exclusiveMaximum"].(bool); ok {
+		delete(schema, "exclusiveMaximum")
+		if max, ok := schema["maximum"]; ok && exclusive {
+			delete(schema, "maximum")
+			schema["exclusiveMaximum"] = max
+		}
+	}
+
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		for _, property := range properties {
+			convertSchema31(property)
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		if schemas, ok := schema[key].([]any); ok {
+			for _, s := range schemas {
+				convertSchema31(s)
+			}
+		}
+	}
+	convertSchema31(schema["items"])
+	convertSchema31(schema["not"])
+	convertSchema31(schema["additionalProperties"])
+}
@@ -0,0 +1,74 @@
+package egs
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+type goldenPetReq struct {
+	Name string `form:"name" json:"name" binding:"required" example:"kitty"`
+}
+
+type goldenPetQuery struct {
+	Limit int `query:"limit" example:"10"`
+}
+
+type goldenPet struct {
+	ID         int     `json:"id" example:"1"`
+	Name       string  `json:"name" example:"kitty"`
+	Weight     float64 `json:"weight" example:"4.5"`
+	Vaccinated bool    `json:"vaccinated" example:"false"`
+}
+
+func goldenSwagger(version string) *Swagger {
+	swagger := NewSwagger("golden", "", "1.0.0")
+	swagger.OpenAPIVersion = version
+
+	nickname := openapi3.NewStringSchema()
+	nickname.Nullable = true
+	nickname.Example = "tom"
+
+	app := New(swagger)
+	app.POST("/pets", router.NewRouterX(func(c *gin.Context) {},
+		router.Req(router.Request{Model: &goldenPetReq{}}),
+		router.Resp(router.Response{
+			"200": router.ResponseItem{
+				Model: &goldenPet{},
+				Headers: openapi3.Headers{
+					"X-Nickname": &openapi3.HeaderRef{Value: &openapi3.Header{
+						Parameter: openapi3.Parameter{Schema: &openapi3.SchemaRef{Value: nickname}},
+					}},
+				},
+			},
+		}),
+	))
+	app.GET("/pets", router.NewRouter(func(c *gin.Context, req goldenPetQuery) {}))
+	swagger.Webhook("petCreated", http.MethodPost, router.NewRouterX(func(c *gin.Context) {},
+		router.Req(router.Request{Model: &goldenPetReq{}}),
+	))
+	app.MustBuild()
+	return swagger
+}
+
+func TestMarshalGolden(t *testing.T) {
+	tests := []struct {
+		version string
+		golden  string
+	}{
+		{OpenAPI30, "openapi30.golden.json"},
+		{OpenAPI31, "openapi31.golden.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := goldenSwagger(tt.version).MarshalJSONIndent("", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, got)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package egs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Yuukirn/egs/router"
+)
+
+// OperationIDFunc derives the operationId of routers without router.OperationID
+type OperationIDFunc func(r *router.Router) string
+
+// OperationIDFromMethodPath derives operationIds from the method and path,
+// `GET /users/:id/files` becomes `getUsersByIdFiles`
+func OperationIDFromMethodPath(r *router.Router) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(r.Method))
+
+	segments := strings.Split(strings.Trim(r.Path, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		b.WriteString("Root")
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		if segment[0] == ':' || segment[0] == '*' {
+			b.WriteString("By")
+		}
+		b.WriteString(camelCase(segment))
+	}
+	return b.String()
+}
+
+// OperationIDFromHandler derives operationIds from the name of the handler function,
+// `TestApi` or `UserHandlerList` for the method `(*UserHandler).List`.
+// Anonymous functions fall back to OperationIDFromMethodPath.
+func OperationIDFromHandler(r *router.Router) string {
+	name := r.HandlerName
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// drop the package name
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || isAnonymous(name) {
+		return OperationIDFromMethodPath(r)
+	}
+	// keep the case of the function name, `hello` stays `hello`
+	id := []rune(camelCase(name))
+	if first := []rune(strings.TrimLeft(name, "(*"))[0]; unicode.IsLower(first) {
+		id[0] = first
+	}
+	return string(id)
+}
+
+// isAnonymous reports whether the runtime name is a closure like `main.func1` or `Handler.func2`
+func isAnonymous(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if strings.HasPrefix(part, "func") && len(part) > 4 && unicode.IsDigit(rune(part[4])) {
+			return true
+		}
+	}
+	return false
+}
+
+// camelCase joins the alphanumeric words of s, capitalizing each of them
+func camelCase(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// checkOperationIDs returns an error listing the operationIds used by more than one operation
+func checkOperationIDs(operations map[string][]string) error {
+	var conflicts []string
+	for _, id := range sortedKeys(operations) {
+		if len(operations[id]) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%q is used by %s", id, strings.Join(operations[id], ", ")))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("duplicate operationIds: %s", strings.Join(conflicts, "; "))
+}
@@ -0,0 +1,278 @@
+package egs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+type Option func(e *Egs)
+
+// Engine wraps engine instead of creating one, keeping its middlewares, templates and settings
+func Engine(engine *gin.Engine) Option {
+	return func(e *Egs) {
+		e.Engine = engine
+	}
+}
+
+// Middlewares creates the engine with gin.New() and middlewares instead of the
+// logger and recovery of gin.Default(), it is ignored when Engine is given
+func Middlewares(middlewares ...gin.HandlerFunc) Option {
+	return func(e *Egs) {
+		e.engineMiddlewares = append([]gin.HandlerFunc{}, middlewares...)
+	}
+}
+
+// AutoOptions answers OPTIONS requests of registered paths with an `Allow` header,
+// unless an OPTIONS router is registered for the path
+func AutoOptions() Option {
+	return func(e *Egs) {
+		e.autoOptions = true
+	}
+}
+
+// MethodNotAllowed answers requests of registered paths with a wrong method with
+// 405 and an `Allow` header instead of 404
+func MethodNotAllowed() Option {
+	return func(e *Egs) {
+		e.methodNotAllowed = true
+	}
+}
+
+// ShutdownTimeout is how long the server drains in-flight requests on shutdown, 10s by default
+func ShutdownTimeout(timeout time.Duration) Option {
+	return func(e *Egs) {
+		e.shutdownTimeout = timeout
+	}
+}
+
+// ShutdownSignals are the signals shutting the server down gracefully, SIGINT and
+// SIGTERM by default, no signals are handled when empty
+func ShutdownSignals(signals ...os.Signal) Option {
+	return func(e *Egs) {
+		e.shutdownSignals = signals
+	}
+}
+
+// CORSConfig configures the CORS headers of preflight and actual requests
+type CORSConfig struct {
+	// AllowOrigins are the allowed origins, `*` allows any origin
+	AllowOrigins []string
+	// AllowHeaders are the allowed request headers, the requested headers are allowed when empty
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORS answers preflight requests of registered paths with the methods registered
+// for the path and adds CORS headers to the responses of allowed origins.
+// It implies AutoOptions. AllowCredentials can't be used with the `*` origin, as
+// any site could then make credentialed requests, Build returns an error for it.
+func CORS(config CORSConfig) Option {
+	return func(e *Egs) {
+		if config.AllowCredentials && contains(config.AllowOrigins, "*") {
+			e.errs = append(e.errs, errors.New("cors: AllowCredentials can't be used with the `*` origin, list the allowed origins"))
+		}
+		e.autoOptions = true
+		e.cors = &config
+	}
+}
+
+func (config *CORSConfig) allowOrigin(origin string) string {
+	for _, allowed := range config.AllowOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if allowed == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// middleware adds the CORS headers to the responses of actual requests
+func (config *CORSConfig) middleware(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		c.Next()
+		return
+	}
+	c.Writer.Header().Add("Vary", "Origin")
+	if allowed := config.allowOrigin(origin); allowed != "" {
+		c.Header("Access-Control-Allow-Origin", allowed)
+		if config.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if len(config.ExposeHeaders) != 0 {
+			c.Header("Access-Control-Expose-Headers", strings.Join(config.ExposeHeaders, ", "))
+		}
+	}
+	c.Next()
+}
+
+// preflight answers a preflight request, it returns false for plain OPTIONS requests
+func (config *CORSConfig) preflight(c *gin.Context, methods []string) bool {
+	origin := c.GetHeader("Origin")
+	method := c.GetHeader("Access-Control-Request-Method")
+	if origin == "" || method == "" {
+		return false
+	}
+
+	// `Vary: Origin` is added by the middleware
+	header := c.Writer.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	allowed := config.allowOrigin(origin)
+	if allowed == "" || !contains(methods, method) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return true
+	}
+
+	c.Header("Access-Control-Allow-Origin", allowed)
+	c.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(config.AllowHeaders) != 0 {
+		c.Header("Access-Control-Allow-Headers", strings.Join(config.AllowHeaders, ", "))
+	} else if headers := c.GetHeader("Access-Control-Request-Headers"); headers != "" {
+		c.Header("Access-Control-Allow-Headers", headers)
+	}
+	if config.AllowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+	if config.MaxAge > 0 {
+		c.Header("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge/time.Second)))
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+	return true
+}
+
+// allowedMethods returns the methods registered for each path in registration order,
+// with OPTIONS for the paths which have an automatic OPTIONS router
+func (e *Egs) allowedMethods() ([]string, map[string][]string) {
+	paths, methods := e.registeredMethods()
+	for _, r := range e.generated {
+		if r.Method == http.MethodOptions && !contains(methods[r.Path], http.MethodOptions) {
+			methods[r.Path] = append(methods[r.Path], http.MethodOptions)
+		}
+	}
+	return paths, methods
+}
+
+// registeredMethods returns the methods of the registered routers for each path in
+// registration order
+func (e *Egs) registeredMethods() ([]string, map[string][]string) {
+	var paths []string
+	methods := make(map[string][]string)
+	for _, r := range e.Routers.Routers() {
+		if methods[r.Path] == nil {
+			paths = append(paths, r.Path)
+		}
+		methods[r.Path] = append(methods[r.Path], r.Method)
+	}
+	return paths, methods
+}
+
+// autoOptionsRouters returns the OPTIONS routers of the paths without one. Paths whose
+// OPTIONS route would conflict in gin with an OPTIONS route registered before, like
+// `/users/:id` and `/users/:name/items`, are skipped.
+func (e *Egs) autoOptionsRouters() []*router.Router {
+	if !e.autoOptions {
+		return nil
+	}
+
+	var routers []*router.Router
+	paths, methods := e.registeredMethods()
+	for _, path := range paths {
+		if contains(methods[path], http.MethodOptions) || conflictsWithOptions(path, e.Routers.Routers(), routers) {
+			continue
+		}
+
+		allow := append(methods[path], http.MethodOptions)
+		r := router.NewRouterX(func(c *gin.Context) {
+			if e.cors != nil && e.cors.preflight(c, allow) {
+				return
+			}
+			c.Header("Allow", strings.Join(allow, ", "))
+			c.AbortWithStatus(http.StatusNoContent)
+		},
+			router.Summary("Allowed methods"),
+			router.Resp(router.Response{
+				"204": router.ResponseItem{
+					Description: "The methods allowed for the path",
+					Headers:     allowHeader(),
+				},
+			}),
+		)
+		r.Path = path
+		r.Method = http.MethodOptions
+		r.HandlerName = ""
+		// document the operation next to the other operations of the path
+		for _, registered := range e.Routers.Routers() {
+			if registered.Path == path {
+				r.Tags = append(r.Tags, registered.Tags...)
+				r.Exclude = registered.Exclude
+				break
+			}
+		}
+		routers = append(routers, r)
+	}
+	return routers
+}
+
+// conflictsWithOptions reports whether the OPTIONS route of path can't be registered
+// next to the OPTIONS routes of the registered and generated routers
+func conflictsWithOptions(path string, registered, generated []*router.Router) bool {
+	for _, r := range append(append([]*router.Router(nil), registered...), generated...) {
+		if r.Method == http.MethodOptions && checkConflict(http.MethodOptions, path, r.Method, r.Path) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// noMethod adds the `Allow` header to the 405 responses of gin
+func (e *Egs) noMethod(c *gin.Context) {
+	var allow []string
+	paths, methods := e.allowedMethods()
+	for _, path := range paths {
+		if !matchPath(path, c.Request.URL.Path) {
+			continue
+		}
+		for _, method := range methods[path] {
+			if !contains(allow, method) {
+				allow = append(allow, method)
+			}
+		}
+	}
+	c.Header("Allow", strings.Join(allow, ", "))
+}
+
+func allowHeader() openapi3.Headers {
+	return openapi3.Headers{
+		"Allow": &openapi3.HeaderRef{
+			Value: &openapi3.Header{
+				Parameter: openapi3.Parameter{
+					Description: "The methods allowed for the path",
+					Schema:      openapi3.NewStringSchema().NewRef(),
+				},
+			},
+		},
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, item := range s {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
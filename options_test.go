@@ -0,0 +1,121 @@
+package egs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSRejectsCredentialsWithWildcard(t *testing.T) {
+	app := New(NewSwagger("cors", "", "1.0.0"), CORS(CORSConfig{
+		AllowOrigins:     []string{"*"},
+		AllowCredentials: true,
+	}))
+	if _, err := app.Build(); err == nil {
+		t.Fatal("Build accepted AllowCredentials with the `*` origin")
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      CORSConfig
+		origin      string
+		allow       string
+		credentials string
+	}{
+		{"wildcard", CORSConfig{AllowOrigins: []string{"*"}}, "https://evil.example", "*", ""},
+		{"listed", CORSConfig{AllowOrigins: []string{"https://app.example"}, AllowCredentials: true}, "https://app.example", "https://app.example", "true"},
+		{"not listed", CORSConfig{AllowOrigins: []string{"https://app.example"}, AllowCredentials: true}, "https://evil.example", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(NewSwagger("cors", "", "1.0.0"), CORS(tt.config))
+			app.GET("/items", router.NewRouterX(func(c *gin.Context) {}))
+			handler, err := app.Handler()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allow)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.credentials)
+			}
+		})
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	api := func(c *gin.Context) {}
+	app := New(NewSwagger("options", "", "1.0.0"), Middlewares(), AutoOptions(), MethodNotAllowed())
+	app.GET("/items", router.NewRouterX(api))
+	app.POST("/items", router.NewRouterX(api))
+	app.GET("/users/:id", router.NewRouterX(api))
+	// its OPTIONS route would conflict with the one of /users/:id in gin
+	app.POST("/users/:name/items", router.NewRouterX(api))
+	handler, err := app.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+	}{
+		{http.MethodOptions, "/items", http.StatusNoContent, "GET, POST, OPTIONS"},
+		{http.MethodDelete, "/items", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{http.MethodOptions, "/users/1", http.StatusNoContent, "GET, OPTIONS"},
+		{http.MethodGet, "/users/1/items", http.StatusMethodNotAllowed, "POST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestAutoOptionsDocument(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []Option
+		options204 bool
+		get405     bool
+	}{
+		{"disabled", nil, false, false},
+		{"AutoOptions", []Option{AutoOptions()}, true, false},
+		{"MethodNotAllowed", []Option{MethodNotAllowed()}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(NewSwagger("options", "", "1.0.0"), tt.options...)
+			app.GET("/items", router.NewRouterX(func(c *gin.Context) {}))
+			doc := app.MustBuild()
+
+			item := doc.Paths["/items"]
+			if got := item.Options != nil && item.Options.Responses.Get(http.StatusNoContent) != nil; got != tt.options204 {
+				t.Errorf("OPTIONS 204 documented = %v, want %v", got, tt.options204)
+			}
+			if got := item.Get.Responses.Get(http.StatusMethodNotAllowed) != nil; got != tt.get405 {
+				t.Errorf("GET 405 documented = %v, want %v", got, tt.get405)
+			}
+		})
+	}
+}
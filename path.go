@@ -0,0 +1,175 @@
+package egs
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/structtag"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// pathParamRegexp matches the gin named params `:name` and catch-all params `*name`,
+// a param name lasts until the next `/`
+var pathParamRegexp = regexp.MustCompile(`([:*])([^/]+)`)
+
+// pathParams returns the names of the params in a gin path in order
+func pathParams(path string) []string {
+	var params []string
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		params = append(params, match[2])
+	}
+	return params
+}
+
+// isCatchAll reports whether name is a catch-all param of path
+func isCatchAll(path, name string) bool {
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		if match[2] == name {
+			return match[1] == "*"
+		}
+	}
+	return false
+}
+
+// joinPaths joins paths like gin does, keeping the trailing slash
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && finalPath[len(finalPath)-1] != '/' {
+		return finalPath + "/"
+	}
+	return finalPath
+}
+
+// uriFields returns the names of the `uri` tags of model
+func uriFields(model any) []string {
+	type_ := reflect.TypeOf(model)
+	if type_ == nil {
+		return nil
+	}
+	if type_.Kind() == reflect.Ptr {
+		type_ = type_.Elem()
+	}
+	if type_.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var names []string
+	for i := 0; i < type_.NumField(); i++ {
+		tags, err := structtag.Parse(string(type_.Field(i).Tag))
+		if err != nil {
+			continue
+		}
+		if uriTag, err := tags.Get(URI); err == nil {
+			names = append(names, uriTag.Name)
+		}
+	}
+	return names
+}
+
+// checkPathParams returns an error if a `uri` field of model has no param in path
+func checkPathParams(path string, model any) error {
+	params := make(map[string]bool)
+	for _, name := range pathParams(path) {
+		params[name] = true
+	}
+	for _, name := range uriFields(model) {
+		if !params[name] {
+			return fmt.Errorf("uri field %q has no matching param in path %q", name, path)
+		}
+	}
+	return nil
+}
+
+// checkConflict returns an error if path can't be registered next to the route
+// registered with method and registeredPath: it is a duplicate, gin would panic on
+// its wildcards, or both are documented as the same path with different param names
+func checkConflict(method, path, registeredMethod, registeredPath string) error {
+	if method == registeredMethod && path == registeredPath {
+		return fmt.Errorf("duplicate route")
+	}
+
+	template := pathParamRegexp.ReplaceAllString(path, "$1")
+	if path != registeredPath && template == pathParamRegexp.ReplaceAllString(registeredPath, "$1") {
+		return fmt.Errorf("path params don't match %s %s", registeredMethod, registeredPath)
+	}
+
+	if method != registeredMethod {
+		return nil
+	}
+	segments, registeredSegments := strings.Split(path, "/"), strings.Split(registeredPath, "/")
+	for i := 0; i < len(segments) && i < len(registeredSegments); i++ {
+		segment, registered := segments[i], registeredSegments[i]
+		switch {
+		case strings.HasPrefix(segment, "*") || strings.HasPrefix(registered, "*"):
+			// a catch-all can't share its position with anything else
+			if segment != registered {
+				return fmt.Errorf("%q conflicts with %q of %s %s", segment, registered, registeredMethod, registeredPath)
+			}
+			return nil
+		case strings.HasPrefix(segment, ":") && strings.HasPrefix(registered, ":"):
+			if segment != registered {
+				return fmt.Errorf("%q conflicts with wildcard %q of %s %s", segment, registered, registeredMethod, registeredPath)
+			}
+		case segment != registered:
+			// static segments may be registered next to a named param
+			return nil
+		}
+	}
+	return nil
+}
+
+// matchPath reports whether the request path matches the gin path pattern
+func matchPath(pattern, path string) bool {
+	patternSegments, segments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(segments)
+}
+
+// addPathParameters adds the path params which are not bound by the model
+// and marks all path parameters as required
+func addPathParameters(parameters openapi3.Parameters, path string) openapi3.Parameters {
+	documented := make(map[string]bool)
+	for _, parameter := range parameters {
+		if parameter.Value != nil && parameter.Value.In == openapi3.ParameterInPath {
+			parameter.Value.Required = true
+			documented[parameter.Value.Name] = true
+		}
+	}
+
+	for _, name := range pathParams(path) {
+		if documented[name] {
+			continue
+		}
+		parameter := openapi3.NewPathParameter(name).WithSchema(openapi3.NewStringSchema())
+		if isCatchAll(path, name) {
+			parameter.Description = "the rest of the path, starting with `/`"
+		}
+		parameters = append(parameters, &openapi3.ParameterRef{
+			Value: parameter,
+		})
+	}
+	return parameters
+}
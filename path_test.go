@@ -0,0 +1,83 @@
+package egs
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckConflict(t *testing.T) {
+	tests := []struct {
+		name             string
+		method, path     string
+		registeredMethod string
+		registeredPath   string
+		conflict         bool
+	}{
+		{"duplicate", "GET", "/users", "GET", "/users", true},
+		{"other method", "POST", "/users", "GET", "/users", false},
+		{"named wildcards", "GET", "/users/:id/items", "GET", "/users/:name", true},
+		{"named wildcards of other methods", "POST", "/users/:id/items", "GET", "/users/:name", false},
+		{"static next to a named param", "GET", "/users/new", "GET", "/users/:id", false},
+		{"catch-all next to a static segment", "GET", "/files/list", "GET", "/files/*path", true},
+		{"static segment next to a catch-all", "GET", "/files/*path", "GET", "/files/list", true},
+		{"same catch-all of other methods", "POST", "/files/*path", "GET", "/files/*path", false},
+		// documented as the same path with different params
+		{"param names across methods", "POST", "/users/:name", "GET", "/users/:id", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConflict(tt.method, tt.path, tt.registeredMethod, tt.registeredPath)
+			if (err != nil) != tt.conflict {
+				t.Errorf("checkConflict = %v, want a conflict %v", err, tt.conflict)
+			}
+		})
+	}
+}
+
+type pathTestReq struct {
+	ID string `uri:"id"`
+}
+
+func TestBuildReportsAllRouteErrors(t *testing.T) {
+	api := func(c *gin.Context) {}
+	app := New(NewSwagger("path", "", "1.0.0"))
+	app.GET("/users", router.NewRouterX(api))
+	app.GET("/users/:id", router.NewRouterX(api))
+	app.GET("/files/*path", router.NewRouterX(api))
+
+	app.GET("/users", router.NewRouterX(api))
+	app.GET("/users/:name/items", router.NewRouterX(api))
+	app.GET("/files/list", router.NewRouterX(api))
+	app.POST("/users/:name", router.NewRouterX(api))
+	app.GET("/items/:key", router.NewRouter(func(c *gin.Context, req pathTestReq) {}))
+
+	_, err := app.Build()
+	if err == nil {
+		t.Fatal("Build accepted conflicting routes")
+	}
+	for _, want := range []string{
+		"GET /users: duplicate route",
+		"GET /users/:name/items: \":name\" conflicts with wildcard \":id\"",
+		"GET /files/list: \"list\" conflicts with \"*path\"",
+		"POST /users/:name: path params don't match GET /users/:id",
+		"GET /items/:key: uri field \"id\" has no matching param",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't report %q", err, want)
+		}
+	}
+	if errs := err.(interface{ Unwrap() []error }).Unwrap(); len(errs) != 5 {
+		t.Errorf("got %d errors, want 5", len(errs))
+	}
+
+	// the valid routes are registered
+	for _, path := range []string{"/users", "/users/:id", "/files/*path"} {
+		if app.Routers.Get(path, http.MethodGet) == nil {
+			t.Errorf("GET %s isn't registered", path)
+		}
+	}
+}
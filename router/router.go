@@ -1,6 +1,7 @@
 package router
 
 import (
+	"errors"
 	"github.com/Yuukirn/egs/security"
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,8 @@ import (
 	"github.com/mcuadros/go-defaults"
 	"net/http"
 	"reflect"
+	"runtime"
+	"strings"
 )
 
 type Request struct {
@@ -37,29 +40,47 @@ type EnumItem struct {
 
 type Router struct {
 	// middlewares
-	Handlers            []gin.HandlerFunc
-	Path                string
-	Method              string
-	Summary             string
-	Description         string
-	OperationID         string
-	Deprecated          bool
-	Exclude             bool
+	Handlers    []gin.HandlerFunc
+	Path        string
+	Method      string
+	Summary     string
+	Description string
+	OperationID string
+	Deprecated  bool
+	Exclude     bool
+	// Public routers require no security, even if their groups do
+	Public              bool
 	RequestContentType  string
 	ResponseContentType string
 	Tags                []string
+	// Audiences are the audiences the router is documented for, all of them when empty
+	Audiences []string
 
 	// handler
-	API        gin.HandlerFunc
-	Model      any
-	Securities []security.Security
-	Response   Response
-	Request    Request
-	Enum       Enum
+	API gin.HandlerFunc
+	// Bind binds the request to the model, it runs after the middlewares, right before API
+	Bind gin.HandlerFunc
+	// HandlerName is the fully qualified name of the handler function,
+	// like `github.com/a/b.Handler`, used to look up its doc comment
+	HandlerName string
+	Model       any
+	Securities  []security.Security
+	// Scopes and Permissions are all required, and one of Roles, from the principal
+	// authenticated by the securities, Policies authorize it further
+	Scopes      []string
+	Roles       []string
+	Permissions []string
+	Policies    []Policy
+	Response    Response
+	Request     Request
+	Enum        Enum
 }
 
 type Option func(router *Router)
 
+// Policy authorizes the principal of a request, an error rejects the request with 403
+type Policy func(c *gin.Context, principal *security.Principal) error
+
 func Req(request Request) Option {
 	return func(router *Router) {
 		router.Request = request
@@ -78,6 +99,42 @@ func Security(securities ...security.Security) Option {
 	}
 }
 
+// Scopes requires the principal to have all of scopes, they are listed in the
+// security requirements of OAuth2 and OpenID Connect securities
+func Scopes(scopes ...string) Option {
+	return func(router *Router) {
+		router.Scopes = append(router.Scopes, scopes...)
+	}
+}
+
+// Roles requires the principal to have one of roles
+func Roles(roles ...string) Option {
+	return func(router *Router) {
+		router.Roles = append(router.Roles, roles...)
+	}
+}
+
+// Permissions requires the principal to have all of permissions
+func Permissions(permissions ...string) Option {
+	return func(router *Router) {
+		router.Permissions = append(router.Permissions, permissions...)
+	}
+}
+
+// Policies require all of policies to authorize the principal
+func Policies(policies ...Policy) Option {
+	return func(router *Router) {
+		router.Policies = append(router.Policies, policies...)
+	}
+}
+
+// Public marks the router as requiring no security, overriding the securities of its groups
+func Public() Option {
+	return func(router *Router) {
+		router.Public = true
+	}
+}
+
 func Enums(enums Enum) Option {
 	return func(router *Router) {
 		router.Enum = enums
@@ -90,6 +147,13 @@ func Tags(tags ...string) Option {
 	}
 }
 
+// Audience labels the router, so it is only documented for documents including one of audiences
+func Audience(audiences ...string) Option {
+	return func(router *Router) {
+		router.Audiences = append(router.Audiences, audiences...)
+	}
+}
+
 func Summary(summary string) Option {
 	return func(router *Router) {
 		router.Summary = summary
@@ -120,11 +184,24 @@ func Exclude() Option {
 	}
 }
 
+func RequestContentType(contentType string) Option {
+	return func(router *Router) {
+		router.RequestContentType = contentType
+	}
+}
+
+func ResponseContentType(contentType string) Option {
+	return func(router *Router) {
+		router.ResponseContentType = contentType
+	}
+}
+
 func NewRouterX(f gin.HandlerFunc, options ...Option) *Router {
 	r := &Router{
-		Handlers: make([]gin.HandlerFunc, 0),
-		API:      f,
-		Response: make(Response),
+		Handlers:    make([]gin.HandlerFunc, 0),
+		API:         f,
+		HandlerName: funcName(f),
+		Response:    make(Response),
 	}
 
 	for _, option := range options {
@@ -134,76 +211,110 @@ func NewRouterX(f gin.HandlerFunc, options ...Option) *Router {
 	return r
 }
 
+// Clone returns a copy of the router which can be registered and modified
+// without affecting the original
+func (router *Router) Clone() *Router {
+	r := *router
+	r.Handlers = append([]gin.HandlerFunc(nil), router.Handlers...)
+	r.Tags = append([]string(nil), router.Tags...)
+	r.Audiences = append([]string(nil), router.Audiences...)
+	r.Securities = append([]security.Security(nil), router.Securities...)
+	r.Scopes = append([]string(nil), router.Scopes...)
+	r.Roles = append([]string(nil), router.Roles...)
+	r.Permissions = append([]string(nil), router.Permissions...)
+	r.Policies = append([]Policy(nil), router.Policies...)
+	return &r
+}
+
 func (router *Router) GetHandlers() []gin.HandlerFunc {
 	var handlers []gin.HandlerFunc
 	for _, handler := range router.Handlers {
 		handlers = append(handlers, handler)
 	}
+	if router.Bind != nil {
+		handlers = append(handlers, router.Bind)
+	}
 	handlers = append(handlers, router.API)
 	return handlers
 }
 
 func NewRouter[T any, F func(c *gin.Context, req T)](f F, options ...Option) *Router {
 	var req T
-	bindMiddleware := bindRequest(&req)
 	router := &Router{
 		Response: make(Response),
+		Bind:     bindRequest(&req),
 		API: func(c *gin.Context) {
 			f(c, req)
 		},
-		Model: req,
+		Model:       req,
+		HandlerName: funcName(f),
 	}
 
 	for _, option := range options {
 		option(router)
 	}
 
-	router.Handlers = append(router.Handlers, bindMiddleware)
 	return router
 }
 
+// funcName returns the fully qualified name of the function f
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	// drop the type arguments of generic functions
+	if i := strings.Index(name, "["); i >= 0 {
+		if j := strings.LastIndex(name, "]"); j > i {
+			name = name[:i] + name[j+1:]
+		}
+	}
+	return name
+}
+
 func bindRequest(req any) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		model := reflect.New(reflect.TypeOf(req).Elem()).Interface()
-		if err := c.ShouldBindHeader(model); err != nil {
+		if err := bindError(c.ShouldBindHeader(model)); err != nil {
 			c.AbortWithStatus(http.StatusBadRequest)
 		}
-		if err := c.ShouldBindQuery(model); err != nil {
+		if err := bindError(c.ShouldBindQuery(model)); err != nil {
 			c.AbortWithStatus(http.StatusBadRequest)
 		}
-		if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut {
+		if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut || c.Request.Method == http.MethodPatch {
 			switch c.Request.Header.Get("Content-Type") {
 			case binding.MIMEMultipartPOSTForm:
-				if err := c.ShouldBindWith(model, binding.FormMultipart); err != nil {
+				if err := bindError(c.ShouldBindWith(model, binding.FormMultipart)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			case binding.MIMEJSON:
-				if err := c.ShouldBindJSON(model); err != nil {
+				if err := bindError(c.ShouldBindJSON(model)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			case binding.MIMEXML:
-				if err := c.ShouldBindXML(model); err != nil {
+				if err := bindError(c.ShouldBindXML(model)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			case binding.MIMEPOSTForm:
-				if err := c.ShouldBindWith(model, binding.Form); err != nil {
+				if err := bindError(c.ShouldBindWith(model, binding.Form)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			case binding.MIMEYAML:
-				if err := c.ShouldBindYAML(model); err != nil {
+				if err := bindError(c.ShouldBindYAML(model)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			case binding.MIMEPROTOBUF:
-				if err := c.ShouldBindWith(model, binding.ProtoBuf); err != nil {
+				if err := bindError(c.ShouldBindWith(model, binding.ProtoBuf)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			case binding.MIMEMSGPACK:
-				if err := c.ShouldBindWith(model, binding.MsgPack); err != nil {
+				if err := bindError(c.ShouldBindWith(model, binding.MsgPack)); err != nil {
 					c.AbortWithStatus(http.StatusBadRequest)
 				}
 			}
 		}
-		if err := c.ShouldBindUri(model); err != nil {
+		if err := bindError(c.ShouldBindUri(model)); err != nil {
 			c.AbortWithStatus(http.StatusBadRequest)
 		}
 
@@ -217,3 +328,13 @@ func bindRequest(req any) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// bindError drops the errors of gin's validator, the models are validated by their
+// `validate` tags once all the sources are bound, and `binding` tags only document them
+func bindError(err error) error {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return nil
+	}
+	return err
+}
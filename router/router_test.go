@@ -0,0 +1,47 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type bindTestReq struct {
+	ID   string `uri:"id" validate:"required"`
+	Name string `json:"name" binding:"required"`
+	Age  int    `json:"age" validate:"gte=0"`
+}
+
+func TestBindRequestValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		// binding tags only document the model, like with gin.DisableBindValidation
+		{"binding tag not enforced", `{"age":1}`, http.StatusOK},
+		{"validate tag enforced", `{"name":"a","age":-1}`, http.StatusBadRequest},
+		{"valid", `{"name":"a","age":1}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(func(c *gin.Context, req bindTestReq) {
+				c.Status(http.StatusOK)
+			})
+			engine := gin.New()
+			engine.POST("/items/:id", r.GetHandlers()...)
+
+			req := httptest.NewRequest(http.MethodPost, "/items/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
@@ -1,13 +1,98 @@
 package security
 
 import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
 )
 
+// KeyStore verifies the keys of ApiKey
+type KeyStore interface {
+	// Verify returns the principal owning key, an empty principal is completed with
+	// the key security name as subject, or an error if the key is invalid
+	Verify(ctx context.Context, key string) (*Principal, error)
+}
+
+// Keys is a KeyStore of keys to the subjects owning them
+type Keys map[string]string
+
+func (keys Keys) Verify(ctx context.Context, key string) (*Principal, error) {
+	// compare with every key, so the time taken doesn't depend on the key
+	var subject string
+	found := false
+	for k, s := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			subject, found = s, true
+		}
+	}
+	if !found {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: subject}, nil
+}
+
+// ApiKey documents a key sent in a header, query parameter or cookie, create it with
+// NewApiKey or the Must constructor of its location. It enforces itself when Store is set.
 type ApiKey struct {
 	AuthName string
 	name     string
 	in       string // header query cookie
+	// Store enforces the key when set
+	Store KeyStore
+}
+
+// NewApiKey returns the key sent in the parameter name of in, which is `header`, `query` or `cookie`
+func NewApiKey(authName, name, in string, store KeyStore) (*ApiKey, error) {
+	switch in {
+	case openapi3.ParameterInHeader, openapi3.ParameterInQuery, openapi3.ParameterInCookie:
+	default:
+		return nil, fmt.Errorf("api key %q: invalid location %q, it must be header, query or cookie", authName, in)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("api key %q: empty parameter name", authName)
+	}
+	return &ApiKey{
+		AuthName: authName,
+		name:     name,
+		in:       in,
+		Store:    store,
+	}, nil
+}
+
+// MustHeaderApiKey returns the key sent in the header name, like `X-API-Key`,
+// it panics if name is empty
+func MustHeaderApiKey(authName, name string, store KeyStore) *ApiKey {
+	return mustApiKey(NewApiKey(authName, name, openapi3.ParameterInHeader, store))
+}
+
+// MustQueryApiKey returns the key sent in the query parameter name, it panics if name is empty
+func MustQueryApiKey(authName, name string, store KeyStore) *ApiKey {
+	return mustApiKey(NewApiKey(authName, name, openapi3.ParameterInQuery, store))
+}
+
+// MustCookieApiKey returns the key sent in the cookie name, it panics if name is empty
+func MustCookieApiKey(authName, name string, store KeyStore) *ApiKey {
+	return mustApiKey(NewApiKey(authName, name, openapi3.ParameterInCookie, store))
+}
+
+func mustApiKey(a *ApiKey, err error) *ApiKey {
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// In returns the location of the key
+func (a *ApiKey) In() string {
+	return a.in
+}
+
+// ParamName returns the name of the header, query parameter or cookie of the key
+func (a *ApiKey) ParamName() string {
+	return a.name
 }
 
 func (a *ApiKey) Name() string {
@@ -21,3 +106,28 @@ func (a *ApiKey) Schema() *openapi3.SecurityScheme {
 		Name: a.name,
 	}
 }
+
+func (a *ApiKey) Enforced() bool {
+	return a.Store != nil
+}
+
+// Authenticate verifies the key read from the declared location
+func (a *ApiKey) Authenticate(c *gin.Context) (*Principal, error) {
+	var key string
+	switch a.in {
+	case openapi3.ParameterInHeader:
+		key = c.GetHeader(a.name)
+	case openapi3.ParameterInQuery:
+		key = c.Query(a.name)
+	case openapi3.ParameterInCookie:
+		key, _ = c.Cookie(a.name)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	principal, err := a.Store.Verify(c.Request.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	return complete(principal, a.AuthName, a.AuthName), nil
+}
@@ -1,11 +1,42 @@
 package security
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
 )
 
+// ErrInvalidCredentials is returned by credential stores for unknown users or wrong secrets
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// CredentialStore verifies the credentials of Basic
+type CredentialStore interface {
+	// Verify returns the principal of the user, an empty principal is completed with
+	// the username, or an error if the credentials are invalid
+	Verify(ctx context.Context, username, password string) (*Principal, error)
+}
+
+// Credentials is a CredentialStore of usernames to passwords
+type Credentials map[string]string
+
+func (credentials Credentials) Verify(ctx context.Context, username, password string) (*Principal, error) {
+	expected, ok := credentials[username]
+	// compare anyway, so unknown users take as long as wrong passwords
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(password)) != 1 || !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{}, nil
+}
+
+// Basic documents basic authentication. It enforces itself when Store is set.
 type Basic struct {
 	AuthName string
+	// Realm is sent in the `WWW-Authenticate` header of 401 responses
+	Realm string
+	Store CredentialStore
 }
 
 func (b *Basic) Name() string {
@@ -18,3 +49,40 @@ func (b *Basic) Schema() *openapi3.SecurityScheme {
 		Scheme: "basic",
 	}
 }
+
+func (b *Basic) Enforced() bool {
+	return b.Store != nil
+}
+
+func (b *Basic) Authenticate(c *gin.Context) (*Principal, error) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	principal, err := b.Store.Verify(c.Request.Context(), username, password)
+	if err != nil {
+		return nil, err
+	}
+	return complete(principal, b.AuthName, username), nil
+}
+
+func (b *Basic) Challenge() string {
+	if b.Realm == "" {
+		return "Basic"
+	}
+	return `Basic realm="` + b.Realm + `"`
+}
+
+// complete returns a copy of the principal returned by a store with its security
+// and subject filled in, the store may cache and share its principals
+func complete(principal *Principal, security, subject string) *Principal {
+	var completed Principal
+	if principal != nil {
+		completed = *principal
+	}
+	completed.Security = security
+	if completed.Subject == "" {
+		completed.Subject = subject
+	}
+	return &completed
+}
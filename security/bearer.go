@@ -1,11 +1,29 @@
 package security
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
 )
 
+// Bearer documents a bearer token. It enforces itself when Keys are set,
+// verifying the tokens as JWTs.
 type Bearer struct {
 	AuthName string
+	// BearerFormat documents the format of the token, like `JWT`
+	BearerFormat string
+
+	// Keys verify the JWTs by `kid`, the only key verifies tokens without `kid`.
+	// HMAC secrets are []byte, RSA and ECDSA keys *rsa.PublicKey and *ecdsa.PublicKey.
+	Keys map[string]any
+	// Issuer is the expected `iss` of the tokens, not checked when empty
+	Issuer string
+	// Audience are the accepted `aud` of the tokens, any audience is accepted when empty
+	Audience []string
+	// Leeway is the clock skew tolerated when checking `exp` and `nbf`
+	Leeway time.Duration
 }
 
 func (b *Bearer) Name() string {
@@ -14,7 +32,49 @@ func (b *Bearer) Name() string {
 
 func (b *Bearer) Schema() *openapi3.SecurityScheme {
 	return &openapi3.SecurityScheme{
-		Type:   "http",
-		Scheme: "bearer",
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: b.BearerFormat,
+	}
+}
+
+func (b *Bearer) Enforced() bool {
+	return len(b.Keys) != 0
+}
+
+// Authenticate verifies the JWT of the request, the principal has the `sub`,
+// scopes and claims of the token
+func (b *Bearer) Authenticate(c *gin.Context) (*Principal, error) {
+	token, ok := bearerToken(c)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := verifyJWT(token, func(header jwtHeader) (any, error) {
+		key, ok := lookupKey(b.Keys, header.Kid)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.Kid)
+		}
+		return key, nil
+	}, jwtExpectations{
+		issuer:   b.Issuer,
+		audience: b.Audience,
+		leeway:   b.Leeway,
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return &Principal{
+		Security:    b.AuthName,
+		Subject:     claims.String("sub"),
+		Scopes:      claims.Scopes(),
+		Roles:       claims.strings("roles"),
+		Permissions: claims.strings("permissions"),
+		Claims:      claims,
+	}, nil
+}
+
+func (b *Bearer) Challenge() string {
+	return "Bearer"
 }
@@ -0,0 +1,144 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// signJWT returns the token of claims signed by alg with key, which is a []byte
+// secret or an RSA or ECDSA private key
+func signJWT(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header := map[string]string{"alg": alg}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+
+	hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[2:]]
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg[:2] == "PS" {
+			signature, err = rsa.SignPSS(rand.Reader, key, hash, digest, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, key, digest)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		err = signErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestBearerAuthenticate(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bearer := &Bearer{
+		AuthName: "jwt",
+		Keys: map[string]any{
+			"hs":  secret,
+			"rs":  &rsaKey.PublicKey,
+			"es":  &ecKey.PublicKey,
+			"es3": &ec384Key.PublicKey,
+		},
+		Issuer:   "https://issuer.example",
+		Audience: []string{"api"},
+	}
+	claims := func() map[string]any {
+		return map[string]any{
+			"iss":   "https://issuer.example",
+			"aud":   []string{"api", "other"},
+			"sub":   "alice",
+			"scope": "read",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongIssuer := claims()
+	wrongIssuer["iss"] = "https://evil.example"
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"HS256", signJWT(t, "HS256", "hs", secret, claims()), nil},
+		{"HS512", signJWT(t, "HS512", "hs", secret, claims()), nil},
+		{"RS256", signJWT(t, "RS256", "rs", rsaKey, claims()), nil},
+		{"PS256", signJWT(t, "PS256", "rs", rsaKey, claims()), nil},
+		{"ES256", signJWT(t, "ES256", "es", ecKey, claims()), nil},
+		{"ES384", signJWT(t, "ES384", "es3", ec384Key, claims()), nil},
+		{"wrong secret", signJWT(t, "HS256", "hs", []byte("other"), claims()), ErrInvalidToken},
+		// a public key must not be usable as an HMAC secret
+		{"RSA key as HMAC secret", signJWT(t, "HS256", "rs", rsaKey.N.Bytes(), claims()), ErrInvalidToken},
+		{"HMAC secret for RS256", signJWT(t, "RS256", "hs", rsaKey, claims()), ErrInvalidToken},
+		{"EC key for RS256", signJWT(t, "RS256", "es", rsaKey, claims()), ErrInvalidToken},
+		{"RSA key for ES256", signJWT(t, "ES256", "rs", ecKey, claims()), ErrInvalidToken},
+		{"ES256 with the wrong key", signJWT(t, "ES256", "es3", ecKey, claims()), ErrInvalidToken},
+		{"unknown kid", signJWT(t, "HS256", "other", secret, claims()), ErrInvalidToken},
+		{"no kid with several keys", signJWT(t, "HS256", "", secret, claims()), ErrInvalidToken},
+		{"none", encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, claims()) + ".", ErrInvalidToken},
+		{"expired", signJWT(t, "HS256", "hs", secret, expired), ErrExpiredToken},
+		{"wrong issuer", signJWT(t, "HS256", "hs", secret, wrongIssuer), ErrInvalidToken},
+		{"malformed", "not.a.jwt", ErrInvalidToken},
+		{"no token", "", ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := bearer.Authenticate(bearerContext(tt.token))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && (principal.Subject != "alice" || principal.Security != "jwt" || len(principal.Scopes) != 1) {
+				t.Errorf("principal = %+v", principal)
+			}
+		})
+	}
+}
+
+func TestBearerSingleKeyWithoutKid(t *testing.T) {
+	secret := []byte("secret")
+	bearer := &Bearer{AuthName: "jwt", Keys: map[string]any{"": secret}}
+	token := signJWT(t, "HS256", "", secret, map[string]any{"sub": "alice"})
+	if _, err := bearer.Authenticate(bearerContext(token)); err != nil {
+		t.Fatal(err)
+	}
+}
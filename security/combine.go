@@ -0,0 +1,93 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// combination requires all or any of its securities, it has no schema of its own
+type combination struct {
+	securities []Security
+	all        bool
+}
+
+// All requires all of securities together, like an api key and a signature
+func All(securities ...Security) Security {
+	return &combination{securities: securities, all: true}
+}
+
+// Any requires one of securities, like the securities given to router.Security
+func Any(securities ...Security) Security {
+	return &combination{securities: securities}
+}
+
+func (c *combination) Name() string {
+	names := make([]string, 0, len(c.securities))
+	for _, s := range c.securities {
+		names = append(names, s.Name())
+	}
+	if c.all {
+		return "(" + strings.Join(names, " and ") + ")"
+	}
+	return "(" + strings.Join(names, " or ") + ")"
+}
+
+func (c *combination) Schema() *openapi3.SecurityScheme {
+	return nil
+}
+
+// Validate returns an error if All or Any are given no securities, which would
+// require nothing instead of rejecting every request
+func Validate(securities []Security) error {
+	for _, s := range securities {
+		c, ok := s.(*combination)
+		if !ok {
+			continue
+		}
+		if len(c.securities) == 0 {
+			if c.all {
+				return fmt.Errorf("security: All without securities")
+			}
+			return fmt.Errorf("security: Any without securities")
+		}
+		if err := Validate(c.securities); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Requirements expands the alternatives securities, combined by All and Any, to
+// the sets of securities required together, any of which is sufficient
+func Requirements(securities []Security) [][]Security {
+	var requirements [][]Security
+	for _, s := range securities {
+		requirements = append(requirements, expand(s)...)
+	}
+	return requirements
+}
+
+func expand(s Security) [][]Security {
+	c, ok := s.(*combination)
+	if !ok {
+		return [][]Security{{s}}
+	}
+	if !c.all {
+		return Requirements(c.securities)
+	}
+
+	// the product of the alternatives of each security
+	requirements := [][]Security{{}}
+	for _, s := range c.securities {
+		var product [][]Security
+		for _, requirement := range requirements {
+			for _, alternative := range expand(s) {
+				product = append(product, append(append([]Security(nil), requirement...), alternative...))
+			}
+		}
+		requirements = product
+	}
+	return requirements
+}
@@ -0,0 +1,245 @@
+package security
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval limits reloading the keys for tokens signed with an unknown `kid`
+const jwksRefreshInterval = time.Minute
+
+// jwk is a public key of a JWKS, only RSA and EC keys are supported
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet caches the keys of a JWKS loaded by load
+type keySet struct {
+	load func(ctx context.Context) ([]byte, error)
+
+	mu   sync.RWMutex
+	keys map[string]any
+	// loadedAt is the time of the last load, loadErr its error, which is kept
+	// until the next load so failing loads are limited like successful ones
+	loadedAt time.Time
+	loadErr  error
+	// loading is the load in flight, shared by the requests waiting for it
+	loading *keyLoad
+}
+
+type keyLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// key returns the key with kid, reloading the keys if they aren't loaded yet or kid
+// is unknown, like after the issuer rotated its keys. The keys are reloaded at most
+// once per jwksRefreshInterval, without blocking the lookups of known keys.
+func (s *keySet) key(ctx context.Context, kid string) (any, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := s.reload(ctx); err != nil {
+		return nil, fmt.Errorf("load jwks: %w", err)
+	}
+
+	s.mu.RLock()
+	key, ok = s.lookup(kid)
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// reload loads the keys unless they were loaded recently, or waits for the load in flight
+func (s *keySet) reload(ctx context.Context) error {
+	s.mu.Lock()
+	call := s.loading
+	if call == nil {
+		if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < jwksRefreshInterval {
+			err := s.loadErr
+			s.mu.Unlock()
+			return err
+		}
+		call = &keyLoad{done: make(chan struct{})}
+		s.loading = call
+		// the load is shared, so it isn't canceled with the request starting it
+		go s.loadKeys(context.WithoutCancel(ctx), call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *keySet) loadKeys(ctx context.Context, call *keyLoad) {
+	var keys map[string]any
+	data, err := s.load(ctx)
+	if err == nil {
+		keys, err = parseJWKS(data)
+	}
+
+	s.mu.Lock()
+	// the previous keys are kept when the load fails
+	if err == nil {
+		s.keys = keys
+	}
+	s.loadedAt = time.Now()
+	s.loadErr = err
+	s.loading = nil
+	s.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+func (s *keySet) lookup(kid string) (any, bool) {
+	return lookupKey(s.keys, kid)
+}
+
+// lookupKey returns the key with kid, or the only key when the token has no kid
+func lookupKey(keys map[string]any, kid string) (any, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+func parseJWKS(data []byte) (map[string]any, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the public key, or nil for unsupported key types
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func loadFile(name string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(name)
+	}
+}
+
+func loadURL(client *http.Client, url string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		return fetch(ctx, client, url)
+	}
+}
+
+// defaultHTTPClient fetches the discovery documents and keys when no client is configured
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
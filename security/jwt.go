@@ -0,0 +1,225 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token is expired")
+)
+
+// Claims are the claims of a verified JWT
+type Claims map[string]any
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtExpectations are the registered claims checked after the signature
+type jwtExpectations struct {
+	issuer   string
+	audience []string
+	leeway   time.Duration
+	// requireExp rejects tokens without `exp`, like OpenID Connect tokens
+	requireExp bool
+}
+
+// verifyJWT verifies the signature of token with the key returned by key, which
+// gets the header so it can select the key by `kid`, and checks the registered claims
+func verifyJWT(token string, key func(header jwtHeader) (any, error), expect jwtExpectations) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	k, err := key(header)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, k, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := claims.check(expect); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// verifySignature verifies signature of signed with key, the type of key must
+// match the algorithm, so a public key can't be used as an HMAC secret
+func verifySignature(alg string, key any, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "HS"):
+		secret, ok := key.([]byte)
+		if !ok {
+			break
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidToken
+		}
+		return nil
+	case strings.HasPrefix(alg, "RS"):
+		public, ok := key.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		if rsa.VerifyPKCS1v15(public, hash, digest, signature) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	case strings.HasPrefix(alg, "PS"):
+		public, ok := key.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		if rsa.VerifyPSS(public, hash, digest, signature, nil) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	case strings.HasPrefix(alg, "ES"):
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			break
+		}
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(public, digest, r, s) {
+			return ErrInvalidToken
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	return fmt.Errorf("%w: key doesn't match algorithm %q", ErrInvalidToken, alg)
+}
+
+func (claims Claims) check(expect jwtExpectations) error {
+	now := time.Now()
+	if exp, ok := claims.time("exp"); ok {
+		if now.After(exp.Add(expect.leeway)) {
+			return ErrExpiredToken
+		}
+	} else if expect.requireExp {
+		return fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(expect.leeway).Before(nbf) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if expect.issuer != "" && claims.String("iss") != expect.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if len(expect.audience) != 0 && !claims.hasAudience(expect.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+// String returns the string claim name, or an empty string
+func (claims Claims) String(name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// Scopes returns the space separated `scope` claim, or the `scp` claim
+func (claims Claims) Scopes() []string {
+	if scope := claims.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	return claims.strings("scp")
+}
+
+func (claims Claims) time(name string) (time.Time, bool) {
+	seconds, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// strings returns a claim which is a string or an array of strings
+func (claims Claims) strings(name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func (claims Claims) hasAudience(audience []string) bool {
+	for _, aud := range claims.strings("aud") {
+		for _, expected := range audience {
+			if aud == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
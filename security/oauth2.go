@@ -0,0 +1,82 @@
+package security
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// OAuth2 documents the OAuth2 flows of the authorization server, the flows left
+// nil are not supported
+type OAuth2 struct {
+	AuthName    string
+	Description string
+
+	AuthorizationCode *OAuthFlow
+	ClientCredentials *OAuthFlow
+	Password          *OAuthFlow
+	Implicit          *OAuthFlow
+
+	// scopes are the scopes required by the routers using the security
+	scopes []string
+}
+
+// OAuthFlow is a flow of OAuth2, authorizationCode and implicit flows need
+// AuthorizationURL, authorizationCode, password and clientCredentials flows need TokenURL
+type OAuthFlow struct {
+	AuthorizationURL string
+	TokenURL         string
+	RefreshURL       string
+	// Scopes maps the scopes of the flow to their descriptions
+	Scopes map[string]string
+	// PKCE marks the authorizationCode flow as using PKCE with SHA-256, emitted as `x-usePkce`
+	PKCE bool
+}
+
+func (o *OAuth2) Name() string {
+	return o.AuthName
+}
+
+func (o *OAuth2) Schema() *openapi3.SecurityScheme {
+	return &openapi3.SecurityScheme{
+		Type:        "oauth2",
+		Description: o.Description,
+		Flows: &openapi3.OAuthFlows{
+			AuthorizationCode: o.AuthorizationCode.schema(),
+			ClientCredentials: o.ClientCredentials.schema(),
+			Password:          o.Password.schema(),
+			Implicit:          o.Implicit.schema(),
+		},
+	}
+}
+
+// Scopes returns the scopes required by the routers using o
+func (o *OAuth2) Scopes() []string {
+	return o.scopes
+}
+
+// WithScopes returns a copy of o requiring scopes, like
+// router.Security(auth.WithScopes("users:read"))
+func (o *OAuth2) WithScopes(scopes ...string) *OAuth2 {
+	oauth2 := *o
+	oauth2.scopes = append(append([]string(nil), o.scopes...), scopes...)
+	return &oauth2
+}
+
+func (f *OAuthFlow) schema() *openapi3.OAuthFlow {
+	if f == nil {
+		return nil
+	}
+	flow := &openapi3.OAuthFlow{
+		AuthorizationURL: f.AuthorizationURL,
+		TokenURL:         f.TokenURL,
+		RefreshURL:       f.RefreshURL,
+		// scopes are required even if empty
+		Scopes: make(map[string]string, len(f.Scopes)),
+	}
+	for scope, description := range f.Scopes {
+		flow.Scopes[scope] = description
+	}
+	if f.PKCE {
+		flow.Extensions = map[string]any{"x-usePkce": "SHA-256"}
+	}
+	return flow
+}
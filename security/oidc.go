@@ -0,0 +1,136 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenIDConnect documents an OpenID Connect provider by its discovery url. It
+// enforces itself when Issuer is set, verifying the bearer tokens with the keys
+// of JWKSFile, JWKSURL or the `jwks_uri` of the discovery document, in that order.
+type OpenIDConnect struct {
+	AuthName    string
+	Description string
+	// URL is the discovery url, like `https://issuer/.well-known/openid-configuration`
+	URL string
+
+	// Issuer is the expected `iss` of the tokens
+	Issuer string
+	// Audience are the accepted `aud` of the tokens, any audience is accepted when empty
+	Audience []string
+	JWKSFile string
+	JWKSURL  string
+	// Leeway is the clock skew tolerated when checking `exp` and `nbf`
+	Leeway time.Duration
+	// HTTPClient fetches the discovery document and the keys, a client with a 10s timeout by default
+	HTTPClient *http.Client
+
+	once sync.Once
+	keys *keySet
+}
+
+func (o *OpenIDConnect) Name() string {
+	return o.AuthName
+}
+
+func (o *OpenIDConnect) Schema() *openapi3.SecurityScheme {
+	return &openapi3.SecurityScheme{
+		Type:             "openIdConnect",
+		Description:      o.Description,
+		OpenIdConnectUrl: o.URL,
+	}
+}
+
+func (o *OpenIDConnect) Enforced() bool {
+	return o.Issuer != ""
+}
+
+// Verify verifies token with the keys of the provider and returns its claims,
+// checking the issuer, audience and expiry
+func (o *OpenIDConnect) Verify(ctx context.Context, token string) (Claims, error) {
+	if o.Issuer == "" {
+		return nil, errors.New("no issuer configured")
+	}
+	o.once.Do(func() {
+		o.keys = &keySet{load: o.loadJWKS}
+	})
+	return verifyJWT(token, func(header jwtHeader) (any, error) {
+		// public keys only, the keys of the issuer can't be HMAC secrets
+		if strings.HasPrefix(header.Alg, "HS") {
+			return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+		}
+		return o.keys.key(ctx, header.Kid)
+	}, jwtExpectations{
+		issuer:     o.Issuer,
+		audience:   o.Audience,
+		leeway:     o.Leeway,
+		requireExp: true,
+	})
+}
+
+// Authenticate verifies the bearer token of the request, the principal has the
+// `sub`, scopes and claims of the token
+func (o *OpenIDConnect) Authenticate(c *gin.Context) (*Principal, error) {
+	token, ok := bearerToken(c)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := o.Verify(c.Request.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		Security:    o.AuthName,
+		Subject:     claims.String("sub"),
+		Scopes:      claims.Scopes(),
+		Roles:       claims.strings("roles"),
+		Permissions: claims.strings("permissions"),
+		Claims:      claims,
+	}, nil
+}
+
+func (o *OpenIDConnect) loadJWKS(ctx context.Context) ([]byte, error) {
+	switch {
+	case o.JWKSFile != "":
+		return loadFile(o.JWKSFile)(ctx)
+	case o.JWKSURL != "":
+		return loadURL(o.HTTPClient, o.JWKSURL)(ctx)
+	case o.URL != "":
+		data, err := fetch(ctx, o.HTTPClient, o.URL)
+		if err != nil {
+			return nil, err
+		}
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := json.Unmarshal(data, &discovery); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if discovery.Issuer != o.Issuer {
+			return nil, fmt.Errorf("discovery: issuer %q doesn't match %q", discovery.Issuer, o.Issuer)
+		}
+		return fetch(ctx, o.HTTPClient, discovery.JWKSURI)
+	}
+	return nil, errors.New("no jwks configured")
+}
+
+// bearerToken returns the token of the `Authorization: Bearer` header
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,276 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testIssuer is an in-process OpenID Connect provider serving its discovery
+// document and the JWKS of its RSA keys
+type testIssuer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	keys      map[string]*rsa.PrivateKey
+	jwksLoads atomic.Int32
+}
+
+func newTestIssuer(t *testing.T, kids ...string) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{keys: make(map[string]*rsa.PrivateKey)}
+	for _, kid := range kids {
+		issuer.addKey(t, kid)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer.URL,
+			"jwks_uri": issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksLoads.Add(1)
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		var keys []map[string]string
+		for kid, key := range issuer.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (issuer *testIssuer) addKey(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.mu.Lock()
+	issuer.keys[kid] = key
+	issuer.mu.Unlock()
+}
+
+// sign returns the RS256 token of claims signed with the key kid
+func (issuer *testIssuer) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	issuer.mu.Lock()
+	key := issuer.keys[kid]
+	issuer.mu.Unlock()
+
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (issuer *testIssuer) claims() map[string]any {
+	return map[string]any{
+		"iss":   issuer.URL,
+		"aud":   "api",
+		"sub":   "alice",
+		"scope": "read write",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func bearerContext(token string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c
+}
+
+func TestOpenIDConnectAuthenticate(t *testing.T) {
+	issuer := newTestIssuer(t, "k1")
+	oidc := &OpenIDConnect{
+		AuthName: "oidc",
+		URL:      issuer.URL + "/.well-known/openid-configuration",
+		Issuer:   issuer.URL,
+		Audience: []string{"api"},
+	}
+
+	with := func(name string, value any) map[string]any {
+		claims := issuer.claims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	hs256 := func() string {
+		signed := encodeSegment(t, map[string]string{"alg": "HS256", "kid": "k1"}) + "." + encodeSegment(t, issuer.claims())
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(signed))
+		return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", issuer.sign(t, "k1", issuer.claims()), nil},
+		{"no token", "", ErrNoCredentials},
+		{"wrong iss", issuer.sign(t, "k1", with("iss", "https://evil.example")), ErrInvalidToken},
+		{"wrong aud", issuer.sign(t, "k1", with("aud", "other")), ErrInvalidToken},
+		{"expired", issuer.sign(t, "k1", with("exp", time.Now().Add(-time.Hour).Unix())), ErrExpiredToken},
+		{"missing exp", issuer.sign(t, "k1", with("exp", nil)), ErrInvalidToken},
+		{"HS256", hs256(), ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := oidc.Authenticate(bearerContext(tt.token))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if principal.Subject != "alice" || principal.Security != "oidc" || len(principal.Scopes) != 2 {
+				t.Errorf("principal = %+v", principal)
+			}
+		})
+	}
+}
+
+func TestOpenIDConnectKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t, "k1")
+	oidc := &OpenIDConnect{
+		AuthName: "oidc",
+		Issuer:   issuer.URL,
+		JWKSURL:  issuer.URL + "/jwks",
+	}
+	if _, err := oidc.Authenticate(bearerContext(issuer.sign(t, "k1", issuer.claims()))); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer.addKey(t, "k2")
+	rotated := issuer.sign(t, "k2", issuer.claims())
+	// unknown keys are reloaded at most once per jwksRefreshInterval
+	if _, err := oidc.Authenticate(bearerContext(rotated)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("err = %v, want an unknown key before the refresh interval", err)
+	}
+	if loads := issuer.jwksLoads.Load(); loads != 1 {
+		t.Fatalf("jwks loaded %d times, want 1", loads)
+	}
+
+	oidc.keys.mu.Lock()
+	oidc.keys.loadedAt = time.Now().Add(-jwksRefreshInterval)
+	oidc.keys.mu.Unlock()
+	if _, err := oidc.Authenticate(bearerContext(rotated)); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if loads := issuer.jwksLoads.Load(); loads != 2 {
+		t.Fatalf("jwks loaded %d times, want 2", loads)
+	}
+}
+
+func TestKeySetSharesLoads(t *testing.T) {
+	release := make(chan struct{})
+	var loads atomic.Int32
+	keys := &keySet{load: func(ctx context.Context) ([]byte, error) {
+		loads.Add(1)
+		<-release
+		return nil, errors.New("unavailable")
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keys.key(context.Background(), "kid"); err == nil {
+				t.Error("key found without keys")
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// the failed load isn't retried before jwksRefreshInterval
+	if _, err := keys.key(context.Background(), "kid"); err == nil {
+		t.Error("key found without keys")
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+}
+
+func TestKeySetLookupDuringLoad(t *testing.T) {
+	release := make(chan struct{})
+	keys := &keySet{
+		load: func(ctx context.Context) ([]byte, error) {
+			<-release
+			return nil, errors.New("unavailable")
+		},
+		keys: map[string]any{"known": "key"},
+	}
+	defer close(release)
+
+	go func() {
+		_, _ = keys.key(context.Background(), "unknown")
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := keys.key(context.Background(), "known"); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the lookup of a known key waits for the load")
+	}
+
+	// requests give up waiting for the load with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := keys.key(ctx, "unknown"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context error", err)
+	}
+}
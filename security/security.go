@@ -1,11 +1,62 @@
 package security
 
 import (
+	"errors"
+
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
 )
 
-// Security TODO add oauth and oicd
 type Security interface {
 	Schema() *openapi3.SecurityScheme
 	Name() string
 }
+
+// Scopes is implemented by securities requiring scopes, which are listed in the
+// security requirements of the operations
+type Scopes interface {
+	Scopes() []string
+}
+
+// Authenticator is implemented by securities which can enforce themselves. The
+// routers using only enforced securities reject the requests none of them authenticates.
+type Authenticator interface {
+	// Enforced reports whether the security is configured to authenticate requests
+	Enforced() bool
+	// Authenticate returns the caller of the request, or an error if the request
+	// doesn't carry valid credentials
+	Authenticate(c *gin.Context) (*Principal, error)
+}
+
+// Challenger is implemented by authenticators sending a `WWW-Authenticate` challenge with 401 responses
+type Challenger interface {
+	Challenge() string
+}
+
+// ErrNoCredentials is returned by Authenticate when the request has no credentials for the security
+var ErrNoCredentials = errors.New("no credentials")
+
+// PrincipalKey is the key of the Principal in the gin context
+const PrincipalKey = "egs/principal"
+
+// Principal is the caller authenticated by a security
+type Principal struct {
+	// Security is the name of the security which authenticated the caller
+	Security    string
+	Subject     string
+	Scopes      []string
+	Roles       []string
+	Permissions []string
+	// Claims are the claims of the token, if authenticated by one
+	Claims Claims
+}
+
+// GetPrincipal returns the caller authenticated for the request
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	principal, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := principal.(*Principal)
+	return p, ok && p != nil
+}
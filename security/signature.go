@@ -0,0 +1,221 @@
+package security
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// Parameters is implemented by securities requiring request parameters besides the
+// one of their scheme, which are documented on the operations
+type Parameters interface {
+	Parameters() openapi3.Parameters
+}
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signature documents and verifies HMAC-SHA256 signed requests. The signature is
+// the hex encoded HMAC of
+//
+//	timestamp + "\n" + method + "\n" + request uri + "\n" + hex(sha256(body))
+//
+// sent with the unix timestamp and the id of the key in their own headers. It
+// enforces itself when Keys are set.
+type Signature struct {
+	AuthName string
+	// SignatureHeader is `X-Signature` by default, a `sha256=` prefix of its value is accepted
+	SignatureHeader string
+	// TimestampHeader is `X-Timestamp` by default
+	TimestampHeader string
+	// KeyIDHeader is `X-Key-Id` by default, every key is tried for requests without it
+	KeyIDHeader string
+
+	// Keys are the secrets by key id, keep the old and the new key during a rotation
+	Keys map[string][]byte
+	// Window is the accepted difference between the timestamp and the time of the
+	// server, which limits replaying requests, 5 minutes by default
+	Window time.Duration
+	// MaxBodySize is the size of the largest body read to verify the signature,
+	// 10 MiB by default, larger requests are rejected
+	MaxBodySize int64
+}
+
+func (s *Signature) Name() string {
+	return s.AuthName
+}
+
+func (s *Signature) Schema() *openapi3.SecurityScheme {
+	return &openapi3.SecurityScheme{
+		Type: "apiKey",
+		In:   openapi3.ParameterInHeader,
+		Name: s.signatureHeader(),
+		Description: fmt.Sprintf("Hex encoded HMAC-SHA256 of `timestamp\\nmethod\\nrequest uri\\nhex(sha256(body))`, "+
+			"with the unix timestamp in `%s` and the key id in `%s`", s.timestampHeader(), s.keyIDHeader()),
+	}
+}
+
+// Parameters documents the timestamp and key id headers
+func (s *Signature) Parameters() openapi3.Parameters {
+	timestamp := openapi3.NewHeaderParameter(s.timestampHeader()).
+		WithRequired(true).
+		WithDescription("Unix timestamp of the signature").
+		WithSchema(openapi3.NewInt64Schema())
+	keyID := openapi3.NewHeaderParameter(s.keyIDHeader()).
+		WithDescription("Id of the key of the signature").
+		WithSchema(openapi3.NewStringSchema())
+	return openapi3.Parameters{
+		{Value: timestamp},
+		{Value: keyID},
+	}
+}
+
+func (s *Signature) Enforced() bool {
+	return len(s.Keys) != 0
+}
+
+// Authenticate verifies the signature of the request, the subject of the principal is the key id
+func (s *Signature) Authenticate(c *gin.Context) (*Principal, error) {
+	signature := strings.TrimPrefix(c.GetHeader(s.signatureHeader()), "sha256=")
+	if signature == "" {
+		return nil, ErrNoCredentials
+	}
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(c.GetHeader(s.timestampHeader()), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+	}
+	timestamp := time.Unix(seconds, 0)
+	if age := time.Since(timestamp); age > s.window() || age < -s.window() {
+		return nil, fmt.Errorf("%w: timestamp is outside the window", ErrInvalidSignature)
+	}
+
+	keyIDs := []string{c.GetHeader(s.keyIDHeader())}
+	if keyIDs[0] == "" {
+		keyIDs = sortedKeyIDs(s.Keys)
+	} else if _, ok := s.Keys[keyIDs[0]]; !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidSignature, keyIDs[0])
+	}
+
+	// the body is read before the signature is verified, so limit what a client can send
+	if c.Request.Body != nil {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.maxBodySize())
+	}
+	body, err := readBody(c.Request)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, fmt.Errorf("%w: body is larger than %d bytes", ErrInvalidSignature, tooLarge.Limit)
+		}
+		return nil, err
+	}
+
+	for _, keyID := range keyIDs {
+		expected := sign(s.Keys[keyID], timestamp, c.Request.Method, c.Request.URL.RequestURI(), body)
+		if hmac.Equal(mac, expected) {
+			return &Principal{
+				Security: s.AuthName,
+				Subject:  keyID,
+			}, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+// Sign signs req with the key keyID, for clients of the routers using s
+func (s *Signature) Sign(req *http.Request, keyID string) error {
+	secret, ok := s.Keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key %q", keyID)
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set(s.timestampHeader(), strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(s.keyIDHeader(), keyID)
+	req.Header.Set(s.signatureHeader(), hex.EncodeToString(sign(secret, timestamp, req.Method, req.URL.RequestURI(), body)))
+	return nil
+}
+
+func sign(secret []byte, timestamp time.Time, method, uri string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "\n" + method + "\n" + uri + "\n" + hex.EncodeToString(digest[:])))
+	return mac.Sum(nil)
+}
+
+// readBody reads the body of req and replaces it, so it can be read again
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func sortedKeyIDs(keys map[string][]byte) []string {
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Signature) signatureHeader() string {
+	if s.SignatureHeader == "" {
+		return "X-Signature"
+	}
+	return s.SignatureHeader
+}
+
+func (s *Signature) timestampHeader() string {
+	if s.TimestampHeader == "" {
+		return "X-Timestamp"
+	}
+	return s.TimestampHeader
+}
+
+func (s *Signature) keyIDHeader() string {
+	if s.KeyIDHeader == "" {
+		return "X-Key-Id"
+	}
+	return s.KeyIDHeader
+}
+
+func (s *Signature) window() time.Duration {
+	if s.Window == 0 {
+		return 5 * time.Minute
+	}
+	return s.Window
+}
+
+func (s *Signature) maxBodySize() int64 {
+	if s.MaxBodySize == 0 {
+		return 10 << 20
+	}
+	return s.MaxBodySize
+}
@@ -0,0 +1,113 @@
+package security
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingReader fails the test if the body is read
+type failingReader struct {
+	t *testing.T
+}
+
+func (r failingReader) Read([]byte) (int, error) {
+	r.t.Error("the body is read")
+	return 0, io.EOF
+}
+
+func signedContext(t *testing.T, s *Signature, keyID string, body io.Reader, tamper func(req *http.Request)) *gin.Context {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/hooks?a=1", body)
+	if err := s.Sign(req, keyID); err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(req)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return c
+}
+
+// signedAt signs the request again as if it was sent at now plus offset
+func signedAt(s *Signature, offset time.Duration) func(req *http.Request) {
+	return func(req *http.Request) {
+		body, _ := readBody(req)
+		timestamp := time.Now().Add(offset)
+		req.Header.Set(s.timestampHeader(), strconv.FormatInt(timestamp.Unix(), 10))
+		req.Header.Set(s.signatureHeader(), hex.EncodeToString(sign(s.Keys["k1"], timestamp, req.Method, req.URL.RequestURI(), body)))
+	}
+}
+
+func TestSignatureAuthenticate(t *testing.T) {
+	s := &Signature{AuthName: "hmac", Keys: map[string][]byte{"k1": []byte("secret")}, Window: time.Minute, MaxBodySize: 16}
+
+	tests := []struct {
+		name   string
+		body   string
+		tamper func(req *http.Request)
+		err    error
+	}{
+		{"valid", `{"id":1}`, nil, nil},
+		{"no signature", `{"id":1}`, func(req *http.Request) { req.Header.Del("X-Signature") }, ErrNoCredentials},
+		{"tampered body", `{"id":1}`, func(req *http.Request) {
+			req.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+		}, ErrInvalidSignature},
+		{"tampered query", `{"id":1}`, func(req *http.Request) { req.URL.RawQuery = "a=2" }, ErrInvalidSignature},
+		{"body too large", strings.Repeat("a", 17), nil, ErrInvalidSignature},
+		{"timestamp within the window", `{"id":1}`, signedAt(s, -30*time.Second), nil},
+		{"timestamp too old", `{"id":1}`, signedAt(s, -2*time.Minute), ErrInvalidSignature},
+		{"timestamp in the future", `{"id":1}`, signedAt(s, 2*time.Minute), ErrInvalidSignature},
+		{"unknown key", `{"id":1}`, func(req *http.Request) {
+			req.Header.Set("X-Key-Id", "k2")
+			req.Body = io.NopCloser(failingReader{t})
+		}, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := signedContext(t, s, "k1", strings.NewReader(tt.body), tt.tamper)
+			principal, err := s.Authenticate(c)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if principal.Subject != "k1" {
+				t.Errorf("subject = %q, want k1", principal.Subject)
+			}
+			// the body can still be bound by the handler
+			if body, _ := io.ReadAll(c.Request.Body); string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestSignatureKeyRotation(t *testing.T) {
+	s := &Signature{AuthName: "hmac", Keys: map[string][]byte{"old": []byte("old secret"), "new": []byte("new secret")}}
+	withoutKeyID := func(req *http.Request) { req.Header.Del("X-Key-Id") }
+
+	// every key is tried without a key id, the request of a client still using the old key is accepted
+	principal, err := s.Authenticate(signedContext(t, s, "old", strings.NewReader(`{"id":1}`), withoutKeyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "old" {
+		t.Errorf("subject = %q, want old", principal.Subject)
+	}
+
+	other := &Signature{Keys: map[string][]byte{"old": []byte("other secret")}}
+	if _, err := s.Authenticate(signedContext(t, other, "old", strings.NewReader(`{"id":1}`), withoutKeyID)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("err = %v, want %v for an unknown secret", err, ErrInvalidSignature)
+	}
+}
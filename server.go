@@ -0,0 +1,178 @@
+package egs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hook runs on startup or shutdown of the server
+type Hook func(ctx context.Context) error
+
+// OnStartup adds hooks which run in order after the routers are registered and
+// before the server accepts connections, an error stops the server from starting
+func (e *Egs) OnStartup(hooks ...Hook) {
+	e.startupHooks = append(e.startupHooks, hooks...)
+}
+
+// OnShutdown adds hooks which run in reverse order after the server is drained,
+// within the shutdown timeout
+func (e *Egs) OnShutdown(hooks ...Hook) {
+	e.shutdownHooks = append(e.shutdownHooks, hooks...)
+}
+
+// ServeHTTP registers the routers on the first request, so the app can be used as
+// an http.Handler directly
+func (e *Egs) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := e.init(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	e.Engine.ServeHTTP(w, req)
+}
+
+// Run listens on addr, `:$PORT` or `:8080` like gin, and serves until a shutdown signal
+func (e *Egs) Run(addr ...string) error {
+	return e.RunContext(context.Background(), addr...)
+}
+
+// RunContext is like Run but also shuts down when ctx is done
+func (e *Egs) RunContext(ctx context.Context, addr ...string) error {
+	address, err := resolveAddress(addr)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	debugPrint("Listening and serving HTTP on %s", address)
+	return e.Serve(ctx, listener)
+}
+
+// RunTLS is like Run but serves HTTPS
+func (e *Egs) RunTLS(addr, certFile, keyFile string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	debugPrint("Listening and serving HTTPS on %s", addr)
+	return e.ServeTLS(context.Background(), listener, certFile, keyFile)
+}
+
+// RunUnix is like Run but listens on the unix socket file, which is removed on shutdown
+func (e *Egs) RunUnix(file string) error {
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	debugPrint("Listening and serving HTTP on unix:/%s", file)
+	return e.Serve(context.Background(), listener)
+}
+
+// RunFd is like Run but listens on the file descriptor fd
+func (e *Egs) RunFd(fd int) error {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd@%d", fd))
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return err
+	}
+	debugPrint("Listening and serving HTTP on fd@%d", fd)
+	return e.Serve(context.Background(), listener)
+}
+
+// RunListener is like Run but serves listener
+func (e *Egs) RunListener(listener net.Listener) error {
+	debugPrint("Listening and serving HTTP on %s", listener.Addr())
+	return e.Serve(context.Background(), listener)
+}
+
+// Serve registers the routers, runs the startup hooks and serves listener until ctx
+// is done or a shutdown signal is received. Then it stops accepting connections,
+// waits for in-flight requests within the shutdown timeout and runs the shutdown hooks.
+func (e *Egs) Serve(ctx context.Context, listener net.Listener) error {
+	return e.serve(ctx, listener, func(server *http.Server) error {
+		return server.Serve(listener)
+	})
+}
+
+// ServeTLS is like Serve but serves HTTPS
+func (e *Egs) ServeTLS(ctx context.Context, listener net.Listener, certFile, keyFile string) error {
+	return e.serve(ctx, listener, func(server *http.Server) error {
+		return server.ServeTLS(listener, certFile, keyFile)
+	})
+}
+
+func (e *Egs) serve(ctx context.Context, listener net.Listener, serve func(server *http.Server) error) error {
+	if err := e.init(); err != nil {
+		listener.Close()
+		return err
+	}
+	for _, hook := range e.startupHooks {
+		if err := hook(ctx); err != nil {
+			listener.Close()
+			return fmt.Errorf("startup: %w", err)
+		}
+	}
+
+	if len(e.shutdownSignals) != 0 {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, e.shutdownSignals...)
+		defer stop()
+	}
+
+	server := &http.Server{Handler: e.Engine.Handler()}
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(server)
+	}()
+
+	var errs []error
+	select {
+	case err := <-served:
+		// the server failed, the shutdown hooks still release the resources
+		errs = append(errs, err)
+	case <-ctx.Done():
+		debugPrint("Shutting down")
+	}
+
+	// the parent context may be done already, so the timeout is counted from here
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), e.shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown: %w", err))
+	}
+	for i := len(e.shutdownHooks) - 1; i >= 0; i-- {
+		if err := e.shutdownHooks[i](shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func resolveAddress(addr []string) (string, error) {
+	switch len(addr) {
+	case 0:
+		if port := os.Getenv("PORT"); port != "" {
+			return ":" + port, nil
+		}
+		return ":8080", nil
+	case 1:
+		return addr[0], nil
+	default:
+		return "", errors.New("egs: too many addresses")
+	}
+}
+
+func debugPrint(format string, values ...any) {
+	if gin.IsDebugging() {
+		fmt.Fprintf(gin.DefaultWriter, "[EGS-debug] "+format+"\n", values...)
+	}
+}
@@ -0,0 +1,143 @@
+package egs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/gin-gonic/gin"
+)
+
+// listen returns a listener on a free local port
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	app := New(NewSwagger("server", "", "1.0.0"), Middlewares(), ShutdownSignals(), ShutdownTimeout(5*time.Second))
+	app.GET("/slow", router.NewRouterX(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	}))
+
+	listener := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.Serve(ctx, listener)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responded := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responded <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responded <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned %v before the request was drained", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-responded; got.err != nil || got.body != "done" {
+		t.Errorf("in-flight request got %q, %v", got.body, got.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+func TestServeShutdownHooks(t *testing.T) {
+	const timeout = time.Second
+	var order []string
+	hook := func(name string) Hook {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > timeout {
+				t.Errorf("hook %s runs without the shutdown timeout", name)
+			}
+			return nil
+		}
+	}
+	errClose := errors.New("close failed")
+
+	app := New(NewSwagger("server", "", "1.0.0"), Middlewares(), ShutdownSignals(), ShutdownTimeout(timeout))
+	app.OnShutdown(hook("first"), func(ctx context.Context) error { return errClose }, hook("last"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := app.Serve(ctx, listen(t))
+	if !errors.Is(err, errClose) {
+		t.Errorf("err = %v, want the error of the hook", err)
+	}
+	// a failing hook doesn't stop the others
+	if want := []string{"last", "first"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("hooks ran in order %v, want %v", order, want)
+	}
+}
+
+func TestServeStartupHookError(t *testing.T) {
+	errStartup := errors.New("database unavailable")
+	var ran []string
+	app := New(NewSwagger("server", "", "1.0.0"), Middlewares(), ShutdownSignals())
+	app.OnStartup(
+		func(ctx context.Context) error { ran = append(ran, "first"); return errStartup },
+		func(ctx context.Context) error { ran = append(ran, "second"); return nil },
+	)
+
+	listener := listen(t)
+	err := app.Serve(context.Background(), listener)
+	if !errors.Is(err, errStartup) || !strings.HasPrefix(err.Error(), "startup: ") {
+		t.Errorf("err = %v, want the wrapped error of the hook", err)
+	}
+	if want := []string{"first"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("hooks ran %v, want %v", ran, want)
+	}
+	if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept: %v, want the listener closed", err)
+	}
+}
+
+func TestServeHTTPRegistersRoutes(t *testing.T) {
+	app := New(NewSwagger("server", "", "1.0.0"), Middlewares())
+	app.GET("/items", router.NewRouterX(func(c *gin.Context) {
+		c.String(http.StatusOK, "items")
+	}))
+
+	// the routes are registered once, on the first request
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "items" {
+			t.Errorf("request %d got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package egs
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Yuukirn/egs/router"
 	"github.com/Yuukirn/egs/security"
 	"github.com/fatih/structtag"
@@ -10,8 +14,9 @@ import (
 	"github.com/invopop/yaml"
 	"mime/multipart"
 	"net/http"
+	"os"
 	"reflect"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -26,6 +31,15 @@ const (
 	HEADER      = "header"
 	COOKIE      = "cookie"
 	JSON        = "json"
+	EXAMPLE     = "example"
+	// EGS lists the audiences of a field, like `egs:"internal,partner"`
+	EGS = "egs"
+)
+
+// supported versions of the generated document
+const (
+	OpenAPI30 = "3.0.0"
+	OpenAPI31 = "3.1.0"
 )
 
 type Swagger struct {
@@ -37,17 +51,73 @@ type Swagger struct {
 	License        *openapi3.License
 	Version        string
 
+	// OpenAPIVersion is the version of the emitted document, OpenAPI30 by default.
+	// The document is always built as 3.0 and converted when marshaling.
+	OpenAPIVersion string
+
 	DocsUrl    string
 	OpenAPIUrl string
 	RedocUrl   string
 	OpenAPI    *openapi3.T
 
+	// Swagger2Url serves the document converted to Swagger 2.0, disabled when empty
+	Swagger2Url string
+
 	Servers openapi3.Servers
 
-	Routers RouterMap
+	// OperationID derives the operationId of routers without one,
+	// OperationIDFromMethodPath by default and disabled when nil
+	OperationID OperationIDFunc
+
+	Routers *RouterMap
+
+	// Name is the name of the document in the Swagger UI, the title and version by default
+	Name string
+	// Filters select the documented routers, all of them when empty
+	Filters []RouteFilter
+	// Audiences are the audiences the document includes, all of them when empty.
+	// Routers and fields without audiences are documented for all of them, and
+	// components are only generated from the documented routers and fields.
+	Audiences []string
+
+	// Webhooks are only emitted in OpenAPI 3.1 documents
+	Webhooks map[string]map[string]*router.Router
+	webhooks openapi3.Paths
+
+	// set by Egs from its options
+	generated        []*router.Router
+	methodNotAllowed bool
+
+	// schemaTypes are the types the component schemas are generated from
+	schemaTypes map[string]reflect.Type
+	// errs are the collisions found while building
+	errs []error
 
 	SwaggerOptions map[string]any
 	RedocOptions   map[string]any
+	// OAuth configures the "Authorize" dialog of the Swagger UI for OAuth2 securities
+	OAuth *OAuthConfig
+}
+
+// OAuthConfig is passed to `initOAuth` of the Swagger UI, which redirects to the
+// `oauth2-redirect.html` served next to DocsUrl
+type OAuthConfig struct {
+	ClientID string `json:"clientId,omitempty"`
+	// ClientSecret is shown in the Swagger UI, never use it in production
+	ClientSecret string `json:"clientSecret,omitempty"`
+	Realm        string `json:"realm,omitempty"`
+	AppName      string `json:"appName,omitempty"`
+	// Scopes are selected by default
+	Scopes                                    []string          `json:"scopes,omitempty"`
+	ScopeSeparator                            string            `json:"scopeSeparator,omitempty"`
+	AdditionalQueryStringParams               map[string]string `json:"additionalQueryStringParams,omitempty"`
+	UseBasicAuthenticationWithAccessCodeGrant bool              `json:"useBasicAuthenticationWithAccessCodeGrant,omitempty"`
+	UsePkceWithAuthorizationCodeGrant         bool              `json:"usePkceWithAuthorizationCodeGrant,omitempty"`
+}
+
+// oauth2RedirectUrl is the url of the redirect page of the Swagger UI
+func (swagger *Swagger) oauth2RedirectUrl() string {
+	return strings.TrimSuffix(swagger.DocsUrl, "/") + "/oauth2-redirect.html"
 }
 
 func NewSwagger(title, desc, version string) *Swagger {
@@ -55,17 +125,50 @@ func NewSwagger(title, desc, version string) *Swagger {
 		Title:          title,
 		Description:    desc,
 		Version:        version,
+		OpenAPIVersion: OpenAPI30,
+		OperationID:    OperationIDFromMethodPath,
 		DocsUrl:        "/docs",
 		RedocUrl:       "/redoc",
 		OpenAPIUrl:     "/openapi.json",
 		SwaggerOptions: make(map[string]any),
 		RedocOptions:   make(map[string]any),
-		Routers:        make(RouterMap),
+		Routers:        NewRouterMap(),
+		Webhooks:       make(map[string]map[string]*router.Router),
+	}
+}
+
+// Webhook registers r as the operation of the webhook name with the given method
+func (swagger *Swagger) Webhook(name, method string, r *router.Router) {
+	if swagger.Webhooks == nil {
+		swagger.Webhooks = make(map[string]map[string]*router.Router)
+	}
+	if swagger.Webhooks[name] == nil {
+		swagger.Webhooks[name] = make(map[string]*router.Router)
 	}
+	r.Method = method
+	swagger.Webhooks[name][method] = r
 }
 
 func (swagger *Swagger) MarshalJSON() ([]byte, error) {
-	return swagger.OpenAPI.MarshalJSON()
+	if swagger.OpenAPIVersion != OpenAPI31 {
+		return swagger.OpenAPI.MarshalJSON()
+	}
+	return swagger.marshalOpenAPI31()
+}
+
+// MarshalJSONIndent is like MarshalJSON but indents the output, which suits
+// specs committed to a repository and reviewed as diffs
+func (swagger *Swagger) MarshalJSONIndent(prefix, indent string) ([]byte, error) {
+	data, err := swagger.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func (swagger *Swagger) MarshalYaml() ([]byte, error) {
@@ -82,11 +185,35 @@ func (swagger *Swagger) MarshalYaml() ([]byte, error) {
 	return yaml.Marshal(data)
 }
 
-func (swagger *Swagger) BuildOpenAPI() {
+// WriteFile writes the built document to filename, as YAML if it ends with `.yml`
+// or `.yaml` and as indented JSON otherwise
+func (swagger *Swagger) WriteFile(filename string) error {
+	if swagger.OpenAPI == nil {
+		return errors.New("egs: the document is not built")
+	}
+	var data []byte
+	var err error
+	if isYaml(filename) {
+		data, err = swagger.MarshalYaml()
+	} else {
+		data, err = swagger.MarshalJSONIndent("", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o644)
+}
+
+func isYaml(name string) bool {
+	return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+}
+
+// BuildOpenAPI builds the document from the registered routers
+func (swagger *Swagger) BuildOpenAPI() error {
 	components := &openapi3.Components{}
 	components.SecuritySchemes = openapi3.SecuritySchemes{}
 	swagger.OpenAPI = &openapi3.T{
-		OpenAPI: "3.0.0",
+		OpenAPI: OpenAPI30,
 		Info: &openapi3.Info{
 			Title:          swagger.Title,
 			Description:    swagger.Description,
@@ -98,93 +225,174 @@ func (swagger *Swagger) BuildOpenAPI() {
 		Servers:    swagger.Servers,
 		Components: components,
 	}
-	swagger.buildPath()
+	swagger.schemaTypes = make(map[string]reflect.Type)
+	swagger.errs = nil
+
+	operations := make(map[string][]string)
+	swagger.buildPath(operations)
+	swagger.buildWebhooks(operations)
+	return errors.Join(append(swagger.errs, checkOperationIDs(operations))...)
 }
 
-func (swagger *Swagger) buildPath() {
+// buildPath builds the paths and collects operationId -> operations
+func (swagger *Swagger) buildPath(operations map[string][]string) {
 	paths := make(openapi3.Paths)
-	for _, routers := range swagger.Routers {
-		for path, m := range routers {
-			pathItem := &openapi3.PathItem{}
-			for method, r := range m {
-				if r.Exclude {
-					continue
-				}
+	var routers []*router.Router
+	for _, r := range swagger.Routers.Routers() {
+		if swagger.selects(r) {
+			routers = append(routers, r)
+		}
+	}
+	selected := len(routers)
+	routers = append(routers, swagger.generated...)
+	for i, r := range routers {
+		if r.Exclude || !swagger.includes(r.Audiences) {
+			continue
+		}
+		path := swagger.fixPath(r.Path)
+		if paths[path] == nil {
+			if i >= selected {
+				// generated routers are documented next to the selected routers of the path
+				continue
+			}
+			paths[path] = &openapi3.PathItem{}
+		}
+		operation := swagger.buildOperation(r.Method, r)
+		operation.Parameters = addPathParameters(operation.Parameters, r.Path)
+		if (len(authenticators(r)) != 0 || requiresAuthorization(r)) && operation.Responses.Get(http.StatusUnauthorized) == nil {
+			operation.Responses["401"] = unauthorizedResponse()
+		}
+		if requiresAuthorization(r) {
+			if operation.Responses.Get(http.StatusForbidden) == nil {
+				operation.Responses["403"] = forbiddenResponse()
+			}
+			if permissions := permissionsExtension(r); len(permissions) != 0 {
+				operation.Extensions = map[string]any{"x-permissions": permissions}
+			}
+		}
+		if swagger.methodNotAllowed && operation.Responses.Get(http.StatusMethodNotAllowed) == nil {
+			description := "The method is not allowed for the path"
+			operation.Responses["405"] = &openapi3.ResponseRef{
+				Value: &openapi3.Response{
+					Description: &description,
+					Headers:     allowHeader(),
+				},
+			}
+		}
+		if operation.OperationID == "" && swagger.OperationID != nil {
+			operation.OperationID = swagger.OperationID(r)
+		}
+		if operation.OperationID != "" {
+			operations[operation.OperationID] = append(operations[operation.OperationID], r.Method+" "+r.Path)
+		}
+		swagger.setOperation(paths[path], r.Method, operation)
+	}
+	swagger.OpenAPI.Paths = paths
+}
 
-				swagger.getComponentByModel(r.Request.Model, true)
-				for _, resp := range r.Response {
-					swagger.getComponentByModel(resp.Model, false)
-				}
-				swagger.getEnumComponent(r.Enum)
-
-				operation := &openapi3.Operation{
-					Tags:        r.Tags,
-					Summary:     r.Summary,
-					Description: r.Description,
-					OperationID: r.OperationID,
-					Responses:   swagger.getResponsesRef(r.Response, r.RequestContentType),
-					Parameters:  swagger.getParametersByModel(r.Model),
-					Deprecated:  r.Deprecated,
-					Security:    swagger.getSecurity(r.Securities),
-				}
+func (swagger *Swagger) buildWebhooks(operations map[string][]string) {
+	swagger.webhooks = make(openapi3.Paths)
+	for _, name := range sortedKeys(swagger.Webhooks) {
+		pathItem := &openapi3.PathItem{}
+		for _, method := range sortedKeys(swagger.Webhooks[name]) {
+			r := swagger.Webhooks[name][method]
+			if r.Exclude || !swagger.includes(r.Audiences) {
+				continue
+			}
+			operation := swagger.buildOperation(method, r)
+			if operation.OperationID != "" {
+				operations[operation.OperationID] = append(operations[operation.OperationID], "webhook "+method+" "+name)
+			}
+			swagger.setOperation(pathItem, method, operation)
+		}
+		swagger.webhooks[name] = pathItem
+	}
+}
 
-				var requestBody *openapi3.RequestBodyRef
-				reqType := reflect.TypeOf(r.Request.Model)
-				if reqType != nil {
-					requestType := reflect.TypeOf(r.Request.Model)
-					if requestType.Kind() == reflect.Ptr {
-						requestType = requestType.Elem()
-					}
-					requestBody = swagger.getRequestBodyRef(requestType.Name(), r.RequestContentType)
-				}
+func (swagger *Swagger) buildOperation(method string, r *router.Router) *openapi3.Operation {
+	swagger.getComponentByModel(r.Request.Model, true)
+	for _, code := range sortedKeys(r.Response) {
+		swagger.getComponentByModel(r.Response[code].Model, false)
+	}
+	swagger.getEnumComponent(r.Enum)
 
-				switch method {
-				case http.MethodGet:
-					pathItem.Get = operation
-				case http.MethodPost:
-					pathItem.Post = operation
-					operation.RequestBody = requestBody
-				case http.MethodDelete:
-					pathItem.Delete = operation
-				case http.MethodPut:
-					pathItem.Put = operation
-					operation.RequestBody = requestBody
-				case http.MethodPatch:
-					pathItem.Patch = operation
-				case http.MethodHead:
-					pathItem.Head = operation
-				case http.MethodOptions:
-					pathItem.Options = operation
-				case http.MethodConnect:
-					pathItem.Connect = operation
-				case http.MethodTrace:
-					pathItem.Trace = operation
-				}
-			}
-			paths[swagger.fixPath(path)] = pathItem
+	// fall back to the doc comment of the handler
+	summary, description := splitDoc(funcDoc(r.HandlerName))
+	if r.Summary != "" {
+		summary = r.Summary
+	}
+	if r.Description != "" {
+		description = r.Description
+	}
+
+	operation := &openapi3.Operation{
+		Tags:        r.Tags,
+		Summary:     summary,
+		Description: description,
+		OperationID: r.OperationID,
+		Responses:   swagger.getResponsesRef(r.Response, r.ResponseContentType),
+		Parameters:  swagger.getParametersByModel(r.Model),
+		Deprecated:  r.Deprecated,
+		Security:    swagger.getSecurity(r.Securities, r.Public, r.Scopes),
+	}
+
+	for _, parameter := range securityParameters(r) {
+		if operation.Parameters.GetByInAndName(parameter.Value.In, parameter.Value.Name) == nil {
+			operation.Parameters = append(operation.Parameters, parameter)
 		}
 	}
-	swagger.OpenAPI.Paths = paths
+
+	reqType := reflect.TypeOf(r.Request.Model)
+	if reqType != nil && (method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch) {
+		if reqType.Kind() == reflect.Ptr {
+			reqType = reqType.Elem()
+		}
+		operation.RequestBody = swagger.getRequestBodyRef(reqType.Name(), r.RequestContentType)
+	}
+	return operation
+}
+
+func (swagger *Swagger) setOperation(pathItem *openapi3.PathItem, method string, operation *openapi3.Operation) {
+	switch method {
+	case http.MethodGet:
+		pathItem.Get = operation
+	case http.MethodPost:
+		pathItem.Post = operation
+	case http.MethodDelete:
+		pathItem.Delete = operation
+	case http.MethodPut:
+		pathItem.Put = operation
+	case http.MethodPatch:
+		pathItem.Patch = operation
+	case http.MethodHead:
+		pathItem.Head = operation
+	case http.MethodOptions:
+		pathItem.Options = operation
+	case http.MethodConnect:
+		pathItem.Connect = operation
+	case http.MethodTrace:
+		pathItem.Trace = operation
+	}
 }
 
 func (swagger *Swagger) getResponsesRef(response router.Response, contentType string) openapi3.Responses {
 	ret := openapi3.NewResponses()
 	for k, v := range response {
-		type_ := reflect.TypeOf(v.Model)
-		if type_ == nil {
-			continue
-		}
-		if type_.Kind() == reflect.Ptr {
-			type_ = type_.Elem()
-		}
+		// responses without a model have no content, like 204
+		var content openapi3.Content
+		if type_ := reflect.TypeOf(v.Model); type_ != nil {
+			if type_.Kind() == reflect.Ptr {
+				type_ = type_.Elem()
+			}
 
-		schemaRef := openapi3.NewSchemaRef(generateRefName(removePackageName(type_.Name())), nil)
+			schemaRef := openapi3.NewSchemaRef(generateRefName(removePackageName(type_.Name())), nil)
 
-		var content = make(openapi3.Content)
-		if contentType == "" {
-			contentType = binding.MIMEJSON
+			content = make(openapi3.Content)
+			if contentType == "" {
+				contentType = binding.MIMEJSON
+			}
+			content[contentType] = openapi3.NewMediaType().WithSchemaRef(schemaRef)
 		}
-		content[contentType] = openapi3.NewMediaType().WithSchemaRef(schemaRef)
 
 		description := v.Description
 		ret[k] = &openapi3.ResponseRef{
@@ -229,6 +437,15 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 		value_ = value_.Elem()
 	}
 
+	// record the type first, so recursive types refer to the schema being generated
+	if name := removePackageName(type_.Name()); name != "" {
+		if registered, ok := swagger.schemaTypes[name]; ok && registered != type_ {
+			swagger.errs = append(swagger.errs, fmt.Errorf("schema %q is generated from both %s and %s", name, qualifiedName(registered), qualifiedName(type_)))
+			return
+		}
+		swagger.schemaTypes[name] = type_
+	}
+
 	// openapi3.Schemas k -> struct name = title -> struct name
 	// get struct name from request.SchemaName
 	schemaRef := &openapi3.SchemaRef{}
@@ -240,6 +457,9 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 		for i := 0; i < type_.NumField(); i++ {
 			field := type_.Field(i)
 			value := value_.Field(i)
+			if !swagger.includes(fieldAudiences(field)) {
+				continue
+			}
 			tags, err := structtag.Parse(string(field.Tag))
 			if err != nil {
 				panic(err)
@@ -280,7 +500,7 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 
 					schemaRef.Value.Properties[fieldName] = openapi3.NewSchemaRef("", fieldSchema)
 					continue
-				} else if !swagger.checkSchemaExist(field.Type.Name()) {
+				} else if !swagger.schemaExists(field.Type) {
 					swagger.getComponentByModel(reflect.New(field.Type).Elem().Interface(), isRequest)
 				}
 				//schemaRef.Ref = generateRefName(field.Type.Name())
@@ -290,7 +510,7 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 				// check if type.Elem() if built-in type
 				var fieldSchema = swagger.getSchemaByValue(value.Interface())
 				if !isBuiltinType(field.Type.Elem()) {
-					if !swagger.checkSchemaExist(field.Type.Elem().Name()) {
+					if !swagger.schemaExists(field.Type.Elem()) {
 						swagger.getComponentByModel(reflect.New(field.Type.Elem()).Elem().Interface(), isRequest)
 					}
 					fieldSchemaRef := openapi3.NewSchemaRef(generateRefName(field.Type.Elem().Name()), nil)
@@ -299,12 +519,19 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 					descriptionTag, err := tags.Get(DESCRIPTION)
 					if err == nil {
 						fieldSchema.Description = descriptionTag.Name
+					} else {
+						fieldSchema.Description = fieldDoc(type_, field)
 					}
 
 					defaultTag, err := tags.Get(DEFAULT)
 					if err == nil {
 						fieldSchema.Default = defaultTag.Name
 					}
+
+					exampleTag, err := tags.Get(EXAMPLE)
+					if err == nil {
+						fieldSchema.Example = exampleValue(fieldSchema, exampleTag.Value())
+					}
 				}
 
 				schemaRef.Value.Properties[fieldName] = openapi3.NewSchemaRef("", fieldSchema)
@@ -323,7 +550,7 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 					var b = true
 					ap.Has = &b
 				} else if mapValueType.Kind() == reflect.Struct {
-					if !swagger.checkSchemaExist(field.Type.Elem().Name()) {
+					if !swagger.schemaExists(field.Type.Elem()) {
 						swagger.getComponentByModel(reflect.New(field.Type.Elem()).Elem().Interface(), isRequest)
 					}
 					ap.Schema = openapi3.NewSchemaRef(generateRefName(field.Type.Elem().Name()), nil)
@@ -345,6 +572,8 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 				descriptionTag, err := tags.Get(DESCRIPTION)
 				if err == nil {
 					fieldSchema.Description = descriptionTag.Name
+				} else {
+					fieldSchema.Description = fieldDoc(type_, field)
 				}
 
 				defaultTag, err := tags.Get(DEFAULT)
@@ -352,6 +581,11 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 					fieldSchema.Default = defaultTag.Name
 				}
 
+				exampleTag, err := tags.Get(EXAMPLE)
+				if err == nil {
+					fieldSchema.Example = exampleValue(fieldSchema, exampleTag.Value())
+				}
+
 				schemaRef.Value.Properties[fieldName] = openapi3.NewSchemaRef("", fieldSchema)
 			}
 		}
@@ -362,6 +596,7 @@ func (swagger *Swagger) getComponentByModel(model any, isRequest bool) {
 	}
 
 	schemaRef.Value.Title = removePackageName(type_.Name())
+	schemaRef.Value.Description = typeDoc(type_)
 	// if it goes here, the schemaRef has `Value` rather than `Ref`
 	swagger.OpenAPI.Components.Schemas[schemaRef.Value.Title] = schemaRef
 }
@@ -382,6 +617,9 @@ func (swagger *Swagger) getParametersByModel(model interface{}) openapi3.Paramet
 	for i := 0; i < type_.NumField(); i++ {
 		field := type_.Field(i)
 		value := value_.Field(i)
+		if !swagger.includes(fieldAudiences(field)) {
+			continue
+		}
 		tags, err := structtag.Parse(string(field.Tag))
 		if err != nil {
 			panic(err)
@@ -413,6 +651,8 @@ func (swagger *Swagger) getParametersByModel(model interface{}) openapi3.Paramet
 		descriptionTag, err := tags.Get(DESCRIPTION)
 		if err == nil {
 			parameter.Description = descriptionTag.Name
+		} else {
+			parameter.Description = fieldDoc(type_, field)
 		}
 		bindingTag, err := tags.Get(BINDING)
 		if err == nil {
@@ -423,6 +663,10 @@ func (swagger *Swagger) getParametersByModel(model interface{}) openapi3.Paramet
 		if err == nil {
 			schema.Default = defaultTag.Name
 		}
+		exampleTag, err := tags.Get(EXAMPLE)
+		if err == nil {
+			parameter.Example = exampleValue(schema, exampleTag.Value())
+		}
 		parameter.Schema = &openapi3.SchemaRef{
 			Value: schema,
 		}
@@ -433,17 +677,111 @@ func (swagger *Swagger) getParametersByModel(model interface{}) openapi3.Paramet
 	return parameters
 }
 
-func (swagger *Swagger) getSecurity(securities []security.Security) *openapi3.SecurityRequirements {
+// getSecurity returns the security requirements of an operation, nil for operations
+// without securities and empty for public ones. The scopes required by the router
+// are added to the OAuth2 and OpenID Connect requirements.
+func (swagger *Swagger) getSecurity(securities []security.Security, public bool, routerScopes []string) *openapi3.SecurityRequirements {
+	if public {
+		return openapi3.NewSecurityRequirements()
+	}
+	if len(securities) == 0 {
+		return nil
+	}
+
 	securityRequirements := openapi3.NewSecurityRequirements()
-	for _, s := range securities {
-		swagger.OpenAPI.Components.SecuritySchemes[s.Name()] = &openapi3.SecuritySchemeRef{
-			Value: s.Schema(),
+	for _, securities := range security.Requirements(securities) {
+		requirement := openapi3.NewSecurityRequirement()
+		for _, s := range securities {
+			swagger.addSecurityScheme(s)
+			if requirement[s.Name()] == nil {
+				requirement[s.Name()] = []string{}
+			}
+			var scopes []string
+			if scoped, ok := s.(security.Scopes); ok {
+				scopes = scoped.Scopes()
+			}
+			if schemeType := s.Schema().Type; schemeType == "oauth2" || schemeType == "openIdConnect" {
+				scopes = append(scopes, routerScopes...)
+			}
+			for _, scope := range scopes {
+				if !contains(requirement[s.Name()], scope) {
+					requirement[s.Name()] = append(requirement[s.Name()], scope)
+				}
+			}
+		}
+		if !containsRequirement(*securityRequirements, requirement) {
+			securityRequirements.With(requirement)
 		}
-		securityRequirements.With(openapi3.NewSecurityRequirement().Authenticate(s.Name()))
 	}
 	return securityRequirements
 }
 
+func (swagger *Swagger) addSecurityScheme(s security.Security) {
+	schema := s.Schema()
+	if existing, ok := swagger.OpenAPI.Components.SecuritySchemes[s.Name()]; ok && !reflect.DeepEqual(existing.Value, schema) {
+		swagger.errs = append(swagger.errs, fmt.Errorf("security scheme %q is declared with different schemas", s.Name()))
+	} else if !ok {
+		if err := schema.Validate(context.Background()); err != nil {
+			swagger.errs = append(swagger.errs, fmt.Errorf("security scheme %q: %w", s.Name(), err))
+		}
+	}
+	swagger.OpenAPI.Components.SecuritySchemes[s.Name()] = &openapi3.SecuritySchemeRef{
+		Value: schema,
+	}
+}
+
+func containsRequirement(requirements openapi3.SecurityRequirements, requirement openapi3.SecurityRequirement) bool {
+	for _, r := range requirements {
+		if reflect.DeepEqual(r, requirement) {
+			return true
+		}
+	}
+	return false
+}
+
+// includes reports whether something labeled with audiences is documented
+func (swagger *Swagger) includes(audiences []string) bool {
+	if len(audiences) == 0 || len(swagger.Audiences) == 0 {
+		return true
+	}
+	for _, audience := range audiences {
+		if contains(swagger.Audiences, audience) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldAudiences(field reflect.StructField) []string {
+	var audiences []string
+	for _, audience := range strings.Split(field.Tag.Get(EGS), ",") {
+		if audience = strings.TrimSpace(audience); audience != "" {
+			audiences = append(audiences, audience)
+		}
+	}
+	return audiences
+}
+
+// exampleValue converts the `example` tag to the type of schema, it is kept as a
+// string when it can't be converted
+func exampleValue(schema *openapi3.Schema, example string) any {
+	switch schema.Type {
+	case openapi3.TypeInteger:
+		if v, err := strconv.ParseInt(example, 10, 64); err == nil {
+			return v
+		}
+	case openapi3.TypeNumber:
+		if v, err := strconv.ParseFloat(example, 64); err == nil {
+			return v
+		}
+	case openapi3.TypeBoolean:
+		if v, err := strconv.ParseBool(example); err == nil {
+			return v
+		}
+	}
+	return example
+}
+
 func (swagger *Swagger) getSchemaByValue(t interface{}) *openapi3.Schema {
 	var schema *openapi3.Schema
 	var m = float64(0)
@@ -530,15 +868,12 @@ func (swagger *Swagger) getEnumComponentByTag(name, enumTag string) {
 		Enum:        values,
 	}
 
-	if swagger.OpenAPI.Components.Schemas == nil {
-		swagger.OpenAPI.Components.Schemas = make(openapi3.Schemas)
-	}
-
-	swagger.OpenAPI.Components.Schemas[name] = openapi3.NewSchemaRef("", &enumSchema)
+	swagger.setEnumSchema(name, &enumSchema)
 }
 
 func (swagger *Swagger) getEnumComponent(enum router.Enum) {
-	for enumName, enumItem := range enum {
+	for _, enumName := range sortedKeys(enum) {
+		enumItem := enum[enumName]
 		var enumSchema = openapi3.Schema{
 			Type:        enumItem.Kind,
 			Title:       enumName,
@@ -546,7 +881,7 @@ func (swagger *Swagger) getEnumComponent(enum router.Enum) {
 			Enum:        enumItem.Values,
 		}
 
-		swagger.OpenAPI.Components.Schemas[enumName] = openapi3.NewSchemaRef("", &enumSchema)
+		swagger.setEnumSchema(enumName, &enumSchema)
 	}
 }
 
@@ -583,14 +918,40 @@ func (swagger *Swagger) getBasicSchemaByType(typ reflect.Kind) *openapi3.Schema
 	return schema
 }
 
-func (swagger *Swagger) checkSchemaExist(name string) bool {
-	for _, schema := range swagger.OpenAPI.Components.Schemas {
-		if schema.Value != nil && schema.Value.Title == name {
-			return true
+// schemaExists reports whether the schema named after t is generated or being generated,
+// recording a collision if it is generated from another type
+func (swagger *Swagger) schemaExists(t reflect.Type) bool {
+	name := removePackageName(t.Name())
+	registered, ok := swagger.schemaTypes[name]
+	if ok && registered != t {
+		swagger.errs = append(swagger.errs, fmt.Errorf("schema %q is generated from both %s and %s", name, qualifiedName(registered), qualifiedName(t)))
+	}
+	return ok
+}
+
+// setEnumSchema adds the enum schema, recording a collision if name is used by
+// a struct schema or by an enum with other values
+func (swagger *Swagger) setEnumSchema(name string, schema *openapi3.Schema) {
+	if swagger.OpenAPI.Components.Schemas == nil {
+		swagger.OpenAPI.Components.Schemas = make(openapi3.Schemas)
+	}
+
+	if registered, ok := swagger.schemaTypes[name]; ok {
+		swagger.errs = append(swagger.errs, fmt.Errorf("enum %q collides with the schema of %s", name, qualifiedName(registered)))
+		return
+	}
+	if existing, ok := swagger.OpenAPI.Components.Schemas[name]; ok && existing.Value != nil {
+		if existing.Value.Type != schema.Type || !reflect.DeepEqual(existing.Value.Enum, schema.Enum) {
+			swagger.errs = append(swagger.errs, fmt.Errorf("enum %q is declared with different values", name))
+			return
 		}
 	}
+	swagger.OpenAPI.Components.Schemas[name] = openapi3.NewSchemaRef("", schema)
+}
 
-	return false
+// qualifiedName returns the name of t with its package path
+func qualifiedName(t reflect.Type) string {
+	return t.PkgPath() + "." + t.Name()
 }
 
 func generateRefName(structName string) string {
@@ -602,9 +963,9 @@ func removePackageName(name string) string {
 	return split[len(split)-1]
 }
 
+// fixPath converts the gin params `:name` and `*name` to `{name}`
 func (swagger *Swagger) fixPath(path string) string {
-	reg := regexp.MustCompile("/:([0-9a-zA-Z]+)")
-	return reg.ReplaceAllString(path, "/{${1}}")
+	return pathParamRegexp.ReplaceAllString(path, "{${2}}")
 }
 
 func isBuiltinType(t reflect.Type) bool {
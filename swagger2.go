@@ -0,0 +1,285 @@
+package egs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/invopop/yaml"
+)
+
+// Swagger2Issue is a construct of the generated document which can't be
+// represented in Swagger 2.0 and is changed or dropped by the conversion.
+type Swagger2Issue struct {
+	// Location is a json pointer like path to the construct
+	Location string
+	Message  string
+}
+
+func (issue Swagger2Issue) String() string {
+	return issue.Location + ": " + issue.Message
+}
+
+// ToSwagger2 converts the generated document to Swagger 2.0.
+// The returned issues list everything which could not be represented.
+func (swagger *Swagger) ToSwagger2() (*openapi2.T, []Swagger2Issue, error) {
+	if swagger.OpenAPI == nil {
+		return nil, nil, fmt.Errorf("openapi document is not built")
+	}
+
+	// openapi2conv mutates the document, so convert a copy
+	bytes, err := swagger.OpenAPI.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+	doc := &openapi3.T{}
+	if err := json.Unmarshal(bytes, doc); err != nil {
+		return nil, nil, err
+	}
+
+	issues := swagger2Issues(doc)
+	removeUnsupportedSecuritySchemes(doc)
+	removeCookieParameters(doc)
+
+	doc2, err := openapi2conv.FromV3(doc)
+	if err != nil {
+		return nil, issues, err
+	}
+	return doc2, issues, nil
+}
+
+// MarshalSwagger2 returns the Swagger 2.0 json of the generated document.
+// Use ToSwagger2 to get the constructs lost in the conversion.
+func (swagger *Swagger) MarshalSwagger2() ([]byte, error) {
+	doc2, _, err := swagger.ToSwagger2()
+	if err != nil {
+		return nil, err
+	}
+	return doc2.MarshalJSON()
+}
+
+func (swagger *Swagger) MarshalSwagger2Yaml() ([]byte, error) {
+	bytes, err := swagger.MarshalSwagger2()
+	if err != nil {
+		return nil, err
+	}
+
+	var data any
+	err = json.Unmarshal(bytes, &data)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(data)
+}
+
+// supportedSwagger2Scheme reports whether the security scheme can be represented
+// in Swagger 2.0, which has no cookie api keys
+func supportedSwagger2Scheme(scheme *openapi3.SecurityScheme) bool {
+	switch scheme.Type {
+	case "http", "oauth2":
+		return true
+	case "apiKey":
+		return scheme.In != openapi3.ParameterInCookie
+	default:
+		return false
+	}
+}
+
+func removeUnsupportedSecuritySchemes(doc *openapi3.T) {
+	if doc.Components == nil {
+		return
+	}
+	removed := make(map[string]bool)
+	for name, ref := range doc.Components.SecuritySchemes {
+		if ref.Value != nil && !supportedSwagger2Scheme(ref.Value) {
+			removed[name] = true
+			delete(doc.Components.SecuritySchemes, name)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	filter := func(requirements openapi3.SecurityRequirements) openapi3.SecurityRequirements {
+		ret := openapi3.SecurityRequirements{}
+		for _, requirement := range requirements {
+			skip := false
+			for name := range requirement {
+				if removed[name] {
+					skip = true
+				}
+			}
+			if !skip {
+				ret = append(ret, requirement)
+			}
+		}
+		return ret
+	}
+	doc.Security = filter(doc.Security)
+	for _, pathItem := range doc.Paths {
+		for _, operation := range pathItem.Operations() {
+			if operation.Security != nil {
+				requirements := filter(*operation.Security)
+				operation.Security = &requirements
+			}
+		}
+	}
+}
+
+// removeCookieParameters removes the cookie parameters, which Swagger 2.0 doesn't have
+func removeCookieParameters(doc *openapi3.T) {
+	filter := func(parameters openapi3.Parameters) openapi3.Parameters {
+		var ret openapi3.Parameters
+		for _, parameter := range parameters {
+			if parameter.Value == nil || parameter.Value.In != openapi3.ParameterInCookie {
+				ret = append(ret, parameter)
+			}
+		}
+		return ret
+	}
+	for _, pathItem := range doc.Paths {
+		pathItem.Parameters = filter(pathItem.Parameters)
+		for _, operation := range pathItem.Operations() {
+			operation.Parameters = filter(operation.Parameters)
+		}
+	}
+}
+
+func swagger2Issues(doc *openapi3.T) []Swagger2Issue {
+	var issues []Swagger2Issue
+	add := func(location, format string, args ...any) {
+		issues = append(issues, Swagger2Issue{Location: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, server := range doc.Servers {
+		location := fmt.Sprintf("/servers/%d", i)
+		if i > 0 {
+			add(location, "only the first server is used as host and basePath")
+		}
+		if len(server.Variables) != 0 {
+			add(location, "server variables are not supported")
+		}
+		if u, err := url.Parse(server.URL); err != nil || u.Host == "" {
+			add(location, "server url %q is not absolute", server.URL)
+		}
+	}
+
+	if doc.Components != nil {
+		for _, name := range sortedKeys(doc.Components.SecuritySchemes) {
+			scheme := doc.Components.SecuritySchemes[name].Value
+			if scheme == nil {
+				continue
+			}
+			location := "/components/securitySchemes/" + name
+			switch {
+			case scheme.Type == "apiKey" && scheme.In == openapi3.ParameterInCookie:
+				add(location, "cookie api keys are not supported and are removed")
+			case !supportedSwagger2Scheme(scheme):
+				add(location, "security scheme type %q is not supported and is removed", scheme.Type)
+			case scheme.Type == "http" && scheme.Scheme != "basic":
+				add(location, "http %q scheme is converted to an Authorization header api key", scheme.Scheme)
+			case scheme.Type == "oauth2" && scheme.Flows != nil && countFlows(scheme.Flows) > 1:
+				add(location, "only one oauth2 flow is kept")
+			}
+		}
+		for _, name := range sortedKeys(doc.Components.Schemas) {
+			schemaIssues("/components/schemas/"+name, doc.Components.Schemas[name], add)
+		}
+		if len(doc.Components.Callbacks) != 0 {
+			add("/components/callbacks", "callbacks are not supported")
+		}
+		if len(doc.Components.Links) != 0 {
+			add("/components/links", "links are not supported")
+		}
+	}
+
+	for _, path := range sortedKeys(doc.Paths) {
+		pathItem := doc.Paths[path]
+		for _, method := range sortedKeys(pathItem.Operations()) {
+			operation := pathItem.Operations()[method]
+			location := "/paths/" + strings.ReplaceAll(path, "/", "~1") + "/" + strings.ToLower(method)
+
+			for _, parameter := range operation.Parameters {
+				if parameter.Value != nil && parameter.Value.In == openapi3.ParameterInCookie {
+					add(location, "cookie parameter %q is not supported and is removed", parameter.Value.Name)
+				}
+			}
+			if operation.RequestBody != nil && operation.RequestBody.Value != nil {
+				if content := operation.RequestBody.Value.Content; len(content) > 1 {
+					add(location+"/requestBody", "only one request body schema is kept for %d content types", len(content))
+				}
+			}
+			for _, code := range sortedKeys(operation.Responses) {
+				response := operation.Responses[code].Value
+				if response == nil {
+					continue
+				}
+				for _, contentType := range sortedKeys(response.Content) {
+					if contentType != binding.MIMEJSON {
+						add(location+"/responses/"+code, "response schema of %q is dropped, only application/json is converted", contentType)
+					}
+				}
+				if len(response.Links) != 0 {
+					add(location+"/responses/"+code, "links are not supported")
+				}
+			}
+			if len(operation.Callbacks) != 0 {
+				add(location, "callbacks are not supported")
+			}
+			if operation.Servers != nil && len(*operation.Servers) != 0 {
+				add(location, "operation servers are not supported")
+			}
+		}
+	}
+
+	return issues
+}
+
+func schemaIssues(location string, ref *openapi3.SchemaRef, add func(location, format string, args ...any)) {
+	if ref == nil || ref.Ref != "" || ref.Value == nil {
+		return
+	}
+	schema := ref.Value
+	if len(schema.OneOf) != 0 {
+		add(location, "oneOf is not supported")
+	}
+	if len(schema.AnyOf) != 0 {
+		add(location, "anyOf is not supported")
+	}
+	if schema.Not != nil {
+		add(location, "not is not supported")
+	}
+	if schema.Nullable {
+		add(location, "nullable is not supported")
+	}
+	for _, name := range sortedKeys(schema.Properties) {
+		schemaIssues(location+"/properties/"+name, schema.Properties[name], add)
+	}
+	schemaIssues(location+"/items", schema.Items, add)
+	schemaIssues(location+"/additionalProperties", schema.AdditionalProperties.Schema, add)
+}
+
+func countFlows(flows *openapi3.OAuthFlows) int {
+	count := 0
+	for _, flow := range []*openapi3.OAuthFlow{flows.Implicit, flows.Password, flows.ClientCredentials, flows.AuthorizationCode} {
+		if flow != nil {
+			count++
+		}
+	}
+	return count
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+package egs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Yuukirn/egs/router"
+	"github.com/Yuukirn/egs/security"
+	"github.com/gin-gonic/gin"
+)
+
+type swagger2CookieReq struct {
+	Session string `cookie:"sid"`
+	Name    string `query:"name"`
+}
+
+func TestSwagger2RemovesCookies(t *testing.T) {
+	cookie := security.MustCookieApiKey("session", "sid", nil)
+	header := security.MustHeaderApiKey("key", "X-API-Key", nil)
+
+	swagger := NewSwagger("swagger2", "", "1.0.0")
+	app := New(swagger)
+	app.GET("/both", router.NewRouterX(func(c *gin.Context) {}, router.Security(cookie, header)))
+	app.GET("/cookie", router.NewRouterX(func(c *gin.Context) {}, router.Security(cookie)))
+	app.GET("/params", router.NewRouter(func(c *gin.Context, req swagger2CookieReq) {}))
+	app.MustBuild()
+
+	doc, issues, err := swagger.ToSwagger2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.SecurityDefinitions["session"]; ok {
+		t.Error("cookie api key is kept in securityDefinitions")
+	}
+	if _, ok := doc.SecurityDefinitions["key"]; !ok {
+		t.Error("header api key is removed from securityDefinitions")
+	}
+	if security := doc.Paths["/both"].Get.Security; security == nil || len(*security) != 1 || (*security)[0]["key"] == nil {
+		t.Errorf("/both security = %v, want only the header api key", security)
+	}
+	if security := doc.Paths["/cookie"].Get.Security; security != nil && len(*security) != 0 {
+		t.Errorf("/cookie security = %v, want none", security)
+	}
+	if parameters := doc.Paths["/params"].Get.Parameters; len(parameters) != 1 || parameters[0].Name != "name" {
+		t.Errorf("/params parameters = %v, want only the query parameter", parameters)
+	}
+
+	data, err := swagger.MarshalSwagger2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"in":"cookie"`) {
+		t.Errorf("swagger 2.0 output has a cookie parameter: %s", data)
+	}
+
+	for _, location := range []string{"/components/securitySchemes/session", "/paths/~1params/get"} {
+		found := false
+		for _, issue := range issues {
+			if issue.Location == location {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no issue reported for %s: %v", location, issues)
+		}
+	}
+}